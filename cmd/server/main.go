@@ -2,39 +2,49 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"net/http"
 	"os"
+	"redoot/internal/auth"
+	"redoot/internal/config"
 	"redoot/internal/handlers"
+	"redoot/internal/live"
+	"redoot/internal/media"
+	"redoot/internal/migrate"
 	"redoot/internal/msgs"
+	"redoot/internal/notify"
+	"redoot/internal/seed"
+	"redoot/internal/storage"
 	"redoot/internal/types"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"golang.org/x/crypto/bcrypt"
 )
 
-const mongoUri = "mongodb://localhost:27017"
-
-var auth = options.Credential{
-	Username: "root",
-	Password: "example",
-}
-
 type connection struct {
 	con *mongo.Client
 	err error
 }
 
-func setupMongo(ch chan<- connection) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+func setupMongo(ch chan<- connection, db config.Database, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoUri).SetAuth(auth))
+	opts := options.Client().ApplyURI(db.URI)
+	if db.Username != "" {
+		opts.SetAuth(options.Credential{
+			Username: db.Username,
+			Password: db.Password,
+		})
+	}
+
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		log.Fatal("invalid options: ", err)
 	}
@@ -59,238 +69,408 @@ func newStyle() (style *log.Styles) {
 	return
 }
 
-func newMods() (types.User, types.User, types.User, types.User, types.User) {
-	id1, err := primitive.ObjectIDFromHex("65b9521f08488450adcbd92d")
+func setupLog(cfg config.Log) {
+	level, err := log.ParseLevel(cfg.Level)
 	if err != nil {
-		panic(err)
+		level = log.InfoLevel
 	}
-	id2, err := primitive.ObjectIDFromHex("65b9521f08488450adcbd92e")
-	if err != nil {
-		panic(err)
+	log.SetLevel(level)
+
+	switch cfg.Format {
+	case "json":
+		log.SetFormatter(log.JSONFormatter)
+	case "logfmt":
+		log.SetFormatter(log.LogfmtFormatter)
+	default:
+		log.SetFormatter(log.TextFormatter)
 	}
-	id3, err := primitive.ObjectIDFromHex("65b9521f08488450adcbd92f")
-	if err != nil {
-		panic(err)
+}
+
+// openStores connects the configured storage, release disconnects it again.
+// The database is nil with memory storage.
+func openStores(cfg config.Config) (stores storage.Stores, db *mongo.Database, release func()) {
+	switch cfg.Storage {
+	case config.StorageMongo:
+		ch := make(chan connection)
+		defer close(ch)
+		go setupMongo(ch, cfg.Database, time.Duration(cfg.Timeouts.Connect))
+
+		connectionResult := <-ch
+		if connectionResult.err != nil {
+			log.Fatal(msgs.ErrTypeConn, "database connection", connectionResult.err)
+		}
+
+		client := connectionResult.con
+		db = client.Database(cfg.Database.Name)
+		stores = storage.NewMongo(client, db)
+		stores.Blobs = openBlobs(cfg.Media, db)
+
+		return stores, db, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeouts.Shutdown))
+			defer cancel()
+
+			err := client.Disconnect(ctx)
+			if err != nil {
+				log.Fatal(msgs.ErrTypeConn, "database disconnect", err)
+			}
+		}
+	default:
+		log.Warn("using in-memory storage, nothing survives a restart")
+		stores = storage.NewMemory()
+		stores.Blobs = openBlobs(cfg.Media, nil)
+		return stores, nil, func() {}
 	}
-	id4, err := primitive.ObjectIDFromHex("65b954c547c4f420dc911a6c")
-	if err != nil {
-		panic(err)
+}
+
+// openBlobs picks where uploaded media goes, GridFS needs the mongo database
+// which the config already checked is there.
+func openBlobs(cfg config.Media, db *mongo.Database) storage.BlobStore {
+	if cfg.Store == config.MediaGridFS {
+		return storage.NewGridFS(db)
 	}
-	id5, err := primitive.ObjectIDFromHex("65b954c547c4f420dc911a6d")
+
+	blobs, err := storage.NewFiles(cfg.Dir)
 	if err != nil {
-		panic(err)
+		log.Fatal("failed opening the media directory", "reason", err)
+	}
+	return blobs
+}
+
+// upgrade brings the schema up to date before the stores are used, with
+// migrations turned off it only warns about the pending ones.
+func upgrade(cfg config.Config, db *mongo.Database) {
+	if db == nil {
+		return
 	}
 
-	hash1, err := bcrypt.GenerateFromPassword([]byte("password1"), bcrypt.MinCost)
-	if err != nil {
-		panic(err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeouts.Migrate))
+	defer cancel()
+
+	if !cfg.Features.Migrate {
+		states, err := migrate.Status(ctx, db)
+		if err != nil {
+			log.Fatal(msgs.ErrTypeConn, "reading the schema version", err)
+		}
+		pending := 0
+		for _, state := range states {
+			if state.AppliedAt == nil {
+				pending++
+			}
+		}
+		if pending > 0 {
+			log.Warn("schema migrations are pending, run migrate up", "pending", pending)
+		}
+		return
 	}
-	hash2, err := bcrypt.GenerateFromPassword([]byte("password2"), bcrypt.MinCost)
+
+	_, err := migrate.Up(ctx, db)
 	if err != nil {
-		panic(err)
+		log.Fatal(msgs.ErrTypeConn, "migrating the schema", err)
 	}
-	hash3, err := bcrypt.GenerateFromPassword([]byte("password3"), bcrypt.MinCost)
-	if err != nil {
-		panic(err)
+}
+
+// migrateCommand moves the schema up to the latest version, down by one or
+// shows where it stands.
+func migrateCommand(cfg config.Config, action string) {
+	if action != "up" && action != "down" && action != "status" {
+		log.Fatal("unknown migrate action, expected up, down or status", "action", action)
 	}
-	hash4, err := bcrypt.GenerateFromPassword([]byte("password4"), bcrypt.MinCost)
-	if err != nil {
-		panic(err)
+	if cfg.Storage == config.StorageMemory {
+		log.Fatal("memory storage starts empty on every run, it has no schema to migrate")
+	}
+
+	_, db, closeStores := openStores(cfg)
+	defer closeStores()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeouts.Migrate))
+	defer cancel()
+
+	switch action {
+	case "up":
+		applied, err := migrate.Up(ctx, db)
+		if err != nil {
+			log.Fatal("failed migrating up", "reason", err)
+		}
+		log.Info("schema up to date", "applied", len(applied))
+	case "down":
+		reverted, err := migrate.Down(ctx, db)
+		if err == migrate.ErrNothingApplied {
+			log.Warn("nothing to revert")
+			return
+		} else if err != nil {
+			log.Fatal("failed migrating down", "reason", err)
+		}
+		log.Info("reverted", "version", reverted.Version, "description", reverted.Description)
+	case "status":
+		states, err := migrate.Status(ctx, db)
+		if err != nil {
+			log.Fatal("failed reading the schema version", "reason", err)
+		}
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = state.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-25s  %s\n", state.Version, applied, state.Description)
+		}
 	}
-	hash5, err := bcrypt.GenerateFromPassword([]byte("password5"), bcrypt.MinCost)
-	if err != nil {
-		panic(err)
-	}
-
-	mod1 := types.User{
-		ID: id1,
-		Name: "Mod1",
-		Bio: "Dictator",
-		Avatar: "base64encodedfile",
-		Pronouns: "over/lord",
-		Password: string(hash1),
-		Email: "mail@mail.com",
-	}
-	mod2 := types.User{
-		ID: id2,
-		Name: "Mod2",
-		Bio: "Dictator",
-		Avatar: "base64encodedfile",
-		Pronouns: "over/lord",
-		Password: string(hash2),
-		Email: "mail@mail.com",
-	}
-	mod3 := types.User{
-		ID: id3,
-		Name: "Mod3",
-		Bio: "Dictator",
-		Avatar: "base64encodedfile",
-		Pronouns: "over/lord",
-		Password: string(hash3),
-		Email: "mail@mail.com",
-	}
-	user := types.User{
-		ID: id4,
-		Name: "regular_user",
-		Bio: "Dictator",
-		Avatar: "base64encodedfile",
-		Pronouns: "over/lord",
-		Password: string(hash4),
-		Email: "mail@mail.com",
-	}
-	user2 := types.User{
-		ID: id5,
-		Name: "regular_user2",
-		Bio: "Dictator",
-		Avatar: "base64encodedfile",
-		Pronouns: "over/lord",
-		Password: string(hash5),
-		Email: "mail@mail.com",
-	}
-
-	return mod1, mod2, mod3, user, user2
 }
 
-const (
-	LevelsDebug   = "debug"
-	LevelsInfo    = "info"
-	LevelsWarning = "warn"
-	LevelsError   = "error"
-	LevelsFatal   = "fatal"
-)
+func runSeed(cfg config.Config, stores storage.Stores) {
+	seeder := seed.New(stores, cfg.Security.BcryptCost, time.Duration(cfg.Timeouts.Write))
+	err := seeder.Run(seed.Admin{
+		Name:     cfg.Bootstrap.AdminName,
+		Email:    cfg.Bootstrap.AdminEmail,
+		Password: cfg.Bootstrap.AdminPassword,
+	}, cfg.Bootstrap.Fixtures)
+	if err != nil {
+		log.Fatal("failed seeding", "reason", err)
+	}
+}
 
-func setLevel() {
-	switch level := os.Getenv("LOG"); level {
-	case LevelsDebug:
-		log.SetLevel(log.DebugLevel)
-	case LevelsInfo:
-		log.SetLevel(log.InfoLevel)
-	case LevelsWarning:
-		log.SetLevel(log.WarnLevel)
-	case LevelsError:
-		log.SetLevel(log.ErrorLevel)
-	case LevelsFatal:
-		log.SetLevel(log.FatalLevel)
-	default:
-		log.SetLevel(log.InfoLevel)
+// seedCommand bootstraps the admin and loads the fixtures, running it again
+// only adds what is missing.
+func seedCommand(cfg config.Config) {
+	if cfg.Storage == config.StorageMemory {
+		log.Fatal("memory storage is seeded by the server on start, seeding it separately has no effect")
 	}
+	if cfg.Bootstrap.AdminName == "" && cfg.Bootstrap.Fixtures == "" {
+		log.Warn("nothing to seed, set an admin name or a fixtures directory")
+		return
+	}
+
+	stores, db, closeStores := openStores(cfg)
+	defer closeStores()
+	upgrade(cfg, db)
+
+	runSeed(cfg, stores)
+	log.Info("seeding done")
 }
 
-func main() {
-	log.SetStyles(newStyle())
-	setLevel()
-	log.Info("starting")
+// openEvents picks how handlers publish streamed events, the mongo fanout
+// relays them to every replica until stop is called.
+func openEvents(cfg config.Live, db *mongo.Database, hub *live.Hub) (events live.Publisher, stop func()) {
+	if cfg.Fanout != config.FanoutMongo {
+		return hub, func() {}
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	ctx, cancel := context.WithCancel(context.Background())
+	relay := live.NewRelay(db, hub)
+	go relay.Run(ctx)
+	return relay, cancel
+}
 
-	ch := make(chan connection)
-	defer close(ch)
-	go setupMongo(ch)
+func serve(cfg config.Config) {
+	stores, db, closeStores := openStores(cfg)
+	defer closeStores()
+	upgrade(cfg, db)
+	types.Roles = stores.Roles
 
-	var client *mongo.Client
-	connectionResult := <-ch
-	if connectionResult.err != nil {
-		log.Fatal(msgs.ErrTypeConn, "database connection", connectionResult.err)
+	if cfg.Storage == config.StorageMemory {
+		runSeed(cfg, stores)
 	}
 
-	client = connectionResult.con
-	defer func() {
-		err := client.Disconnect(ctx)
+	secret := []byte(cfg.Security.Secret)
+	if len(secret) == 0 {
+		log.Warn("REDOOT_SECRET not set, sessions won't survive a restart")
+		random, err := auth.RandomSecret()
 		if err != nil {
-			log.Fatal(msgs.ErrTypeConn, "database disconnect", err)
+			panic(err)
 		}
-	}()
+		secret = random
+	}
+	auth.SetSecret(secret)
 
-	db := client.Database("redoot")
-	users := db.Collection("users")
-	boards := db.Collection("boards")
-	posts := db.Collection("posts")
-	comments := db.Collection("comments")
+	hub := live.NewHub()
+	events, stopEvents := openEvents(cfg.Live, db, hub)
+	defer stopEvents()
 
-	types.Collections.Users = users
+	library := media.New(stores.Blobs)
+	notifier := notify.New(stores.Users, stores.Notifications, stores.Subscriptions, events, time.Duration(cfg.Timeouts.Write))
 
 	r := gin.Default()
+	r.Use(handlers.Authenticate(stores.Sessions, stores.Users))
 
-	id, err := primitive.ObjectIDFromHex("65b94ef156e6d7c59f478392")
-	if err != nil {
-		panic(err)
+	r.GET("/", func(c *gin.Context) { handlers.MostPopular(c, stores.Posts) })
+	r.GET("/feed", func(c *gin.Context) { handlers.GetFeed(c, stores.Posts, stores.Subscriptions) })
+	r.GET("/subscriptions", func(c *gin.Context) { handlers.GetSubscriptions(c, stores.Subscriptions) })
+
+	r.POST("/sessions", func(c *gin.Context) { handlers.NewSession(c, stores.Sessions, stores.Users) })
+	r.POST("/sessions/refresh", func(c *gin.Context) { handlers.RefreshSession(c, stores.Sessions) })
+	r.DELETE("/sessions", func(c *gin.Context) { handlers.DeleteSession(c, stores.Sessions) })
+
+	r.GET("/users", func(c *gin.Context) { handlers.GetUsers(c, stores.Users) })
+	if cfg.Features.Registration {
+		r.POST("/users", func(c *gin.Context) { handlers.NewUser(c, stores.Users, library) })
+	}
+	r.GET("/users/:id", func(c *gin.Context) { handlers.GetUser(c, stores.Users) })
+	r.PUT("/users/:id", func(c *gin.Context) { handlers.UpdateUser(c, stores.Users, library) })
+	r.PATCH("/users/:id", func(c *gin.Context) { handlers.PatchUser(c, stores.Users, library) })
+	r.DELETE("/users/:id", func(c *gin.Context) { handlers.DeleteUser(c, stores.Users) })
+	r.GET("/search", func(c *gin.Context) { handlers.Search(c, stores.Users, stores.Boards, stores.Posts, stores.Comments) })
+	r.GET("/users/search", func(c *gin.Context) { handlers.SearchUser(c, stores.Users) })
+	r.GET("/users/popular", func(c *gin.Context) { handlers.MostPopularUsers(c, stores.Users) })
+	r.GET("/users/:id/roles", func(c *gin.Context) { handlers.GetRoles(c, stores.Roles) })
+	r.POST("/users/:id/roles", func(c *gin.Context) { handlers.GrantRole(c, stores.Users, stores.Roles) })
+	r.DELETE("/users/:id/roles/:role", func(c *gin.Context) { handlers.RevokeRole(c, stores.Roles) })
+	r.GET("/roles/audit", func(c *gin.Context) { handlers.GetRoleAudit(c, stores.Roles) })
+
+	r.GET("/boards", func(c *gin.Context) { handlers.GetBoards(c, stores.Boards) })
+	r.POST("/boards", func(c *gin.Context) { handlers.NewBoard(c, stores.Boards) })
+	r.GET("/boards/:id", func(c *gin.Context) { handlers.GetBoard(c, stores.Boards) })
+	r.PUT("/boards/:id", func(c *gin.Context) { handlers.UpdateBoard(c, stores.Boards) })
+	r.PATCH("/boards/:id", func(c *gin.Context) { handlers.PatchBoard(c, stores.Boards) })
+	r.DELETE("/boards/:id", func(c *gin.Context) { handlers.DeleteBoard(c, stores.Boards) })
+	r.POST("/boards/:id/restore", func(c *gin.Context) { handlers.RestoreBoard(c, stores.Boards) })
+	r.GET("/boards/:id/trash", func(c *gin.Context) { handlers.GetTrash(c, stores.Boards, stores.Posts, stores.Comments) })
+	r.GET("/boards/search", func(c *gin.Context) { handlers.SearchBoard(c, stores.Boards) })
+	r.POST("/boards/:id/subscription", func(c *gin.Context) { handlers.Subscribe(c, stores.Boards, stores.Subscriptions) })
+	r.DELETE("/boards/:id/subscription", func(c *gin.Context) { handlers.Unsubscribe(c, stores.Subscriptions) })
+	r.GET("/boards/:id/stream", func(c *gin.Context) { handlers.StreamBoard(c, stores.Boards, hub) })
+
+	r.GET("/boards/:id/search", func(c *gin.Context) {
+		handlers.SearchInBoard(c, stores.Users, stores.Boards, stores.Posts, stores.Comments)
+	})
+	r.POST("/boards/:id/posts", func(c *gin.Context) {
+		handlers.NewPost(c, stores.Posts, stores.Boards, stores.Users, library, notifier, events)
+	})
+	r.GET("/boards/:id/posts/:postId", func(c *gin.Context) { handlers.GetPost(c, stores.Posts, stores.Votes) })
+	r.GET("/boards/:id/posts", func(c *gin.Context) { handlers.GetPosts(c, stores.Posts, stores.Votes) })
+	r.PUT("/boards/:id/posts/:postId", func(c *gin.Context) {
+		handlers.UpdatePost(c, stores.Posts, stores.Boards, stores.Users, stores.Revisions, library, notifier)
+	})
+	r.PATCH("/boards/:id/posts/:postId", func(c *gin.Context) {
+		handlers.PatchPost(c, stores.Posts, stores.Boards, stores.Users, stores.Revisions, library, notifier)
+	})
+	r.DELETE("/boards/:id/posts/:postId", func(c *gin.Context) { handlers.DeletePost(c, stores.Posts, stores.Boards, notifier, events) })
+	r.GET("/boards/:id/posts/:postId/search", func(c *gin.Context) {
+		handlers.SearchInPost(c, stores.Users, stores.Boards, stores.Posts, stores.Comments)
+	})
+	r.GET("/boards/:id/posts/:postId/revisions", func(c *gin.Context) { handlers.GetPostRevisions(c, stores.Boards, stores.Posts, stores.Revisions) })
+	r.GET("/boards/:id/posts/:postId/stream", func(c *gin.Context) { handlers.StreamPost(c, stores.Posts, hub) })
+	r.POST("/boards/:id/posts/:postId/restore", func(c *gin.Context) { handlers.RestorePost(c, stores.Posts, stores.Boards, notifier) })
+	r.POST("/boards/:id/posts/:postId/vote", func(c *gin.Context) { handlers.VotePost(c, stores.Posts, stores.Votes, events) })
+	r.DELETE("/boards/:id/posts/:postId/vote", func(c *gin.Context) { handlers.VotePost(c, stores.Posts, stores.Votes, events) })
+
+	r.POST("/boards/:id/posts/:postId/comments", func(c *gin.Context) {
+		handlers.CreateComment(c, stores.Comments, stores.Posts, stores.Users, stores.Boards, notifier, events)
+	})
+	r.GET("/boards/:id/posts/:postId/comments/:commentId", func(c *gin.Context) { handlers.GetComment(c, stores.Comments, stores.Votes) })
+	r.GET("/boards/:id/posts/:postId/comments", func(c *gin.Context) { handlers.GetComments(c, stores.Comments, stores.Votes) })
+	r.PUT("/boards/:id/posts/:postId/comments/:commentId", func(c *gin.Context) {
+		handlers.UpdateComment(c, stores.Boards, stores.Posts, stores.Comments, stores.Users, stores.Revisions, notifier)
+	})
+	r.PATCH("/boards/:id/posts/:postId/comments/:commentId", func(c *gin.Context) {
+		handlers.PatchComment(c, stores.Boards, stores.Posts, stores.Comments, stores.Users, stores.Revisions, notifier)
+	})
+	r.DELETE("/boards/:id/posts/:postId/comments/:commentId", func(c *gin.Context) {
+		handlers.DeleteComment(c, stores.Boards, stores.Posts, stores.Comments, notifier, events)
+	})
+	r.GET("/boards/:id/posts/:postId/comments/:commentId/revisions", func(c *gin.Context) {
+		handlers.GetCommentRevisions(c, stores.Boards, stores.Posts, stores.Comments, stores.Revisions)
+	})
+	r.POST("/boards/:id/posts/:postId/comments/:commentId/restore", func(c *gin.Context) {
+		handlers.RestoreComment(c, stores.Boards, stores.Posts, stores.Comments, notifier)
+	})
+	r.POST("/boards/:id/posts/:postId/comments/:commentId/vote", func(c *gin.Context) { handlers.VoteComment(c, stores.Posts, stores.Comments, stores.Votes, events) })
+	r.DELETE("/boards/:id/posts/:postId/comments/:commentId/vote", func(c *gin.Context) { handlers.VoteComment(c, stores.Posts, stores.Comments, stores.Votes, events) })
+
+	r.GET("/notifications", func(c *gin.Context) { handlers.GetNotifications(c, stores.Notifications) })
+	r.POST("/notifications/read", func(c *gin.Context) { handlers.ReadAllNotifications(c, stores.Notifications) })
+	r.POST("/notifications/:id/read", func(c *gin.Context) { handlers.ReadNotification(c, stores.Notifications) })
+	r.GET("/notifications/preferences", func(c *gin.Context) { handlers.GetNotificationPreferences(c) })
+	r.PUT("/notifications/preferences", func(c *gin.Context) { handlers.UpdateNotificationPreferences(c, stores.Users) })
+	r.GET("/notifications/stream", func(c *gin.Context) { handlers.StreamNotifications(c, hub) })
+
+	r.POST("/media", func(c *gin.Context) { handlers.UploadMedia(c, library) })
+	r.GET("/media/:id", func(c *gin.Context) { handlers.GetMedia(c, library) })
+
+	if cfg.Features.Backup {
+		r.POST("/export", func(c *gin.Context) { handlers.ExportToFile(c, stores.Backup) })
+		r.POST("/import", func(c *gin.Context) { handlers.ImportFromFile(c, stores.Backup) })
 	}
 
-	admin := types.User{
-		ID: id,
-		Name: "Administrator",
-		Bio: "Dictator",
-		Avatar: "base64encodedfile",
-		Pronouns: "over/lord",
-		Password: "passsword",
-		Email: "mail@mail.com",
+	srv := &http.Server{
+		Addr:    cfg.Server.Addr,
+		Handler: r,
 	}
+	// open streams would otherwise hold the graceful shutdown until it times out
+	srv.RegisterOnShutdown(hub.Close)
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(admin.Password), bcrypt.MinCost)
-	if err != nil {
-		panic(err)
+	if cfg.Features.Reaper {
+		go handlers.Reaper(time.Hour, stores.Boards, stores.Posts, stores.Comments)
 	}
-	admin.Password = string(hash)
-	types.AddAdministrators(admin)
 
-	mod1, mod2, mod3, user, user2 := newMods()
+	stopped := make(chan struct{})
+	go func() {
+		handlers.Interrupt(srv)
+		close(stopped)
+	}()
 
-	toAdd := []interface{}{
-		admin,
-		mod1,
-		mod2,
-		mod3,
-		user,
-		user2,
+	var err error
+	if cfg.Server.TLSCert != "" {
+		err = srv.ListenAndServeTLS(cfg.Server.TLSCert, cfg.Server.TLSKey)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatalf("listen: %s\n", err)
 	}
+	<-stopped
+	notifier.Wait()
+}
 
-	_, err = users.InsertMany(ctx, toAdd)
-	if err != nil {
-		panic(err)
-	}
-
-	r.GET("/", func(c *gin.Context) { handlers.MostPopular(c, posts) })
-
-	r.GET("/users", func(c *gin.Context) { handlers.GetUsers(c, users) })
-	r.POST("/users", func(c *gin.Context) { handlers.NewUser(c, users) })
-	r.GET("/users/:id", func(c *gin.Context) { handlers.GetUser(c, users) })
-	r.PUT("/users/:id", func(c *gin.Context) { handlers.UpdateUser(c, users) })
-	r.DELETE("/users/:id", func(c *gin.Context) { handlers.DeleteUser(c, users) })
-	r.GET("/users/search", func(c *gin.Context) { handlers.SearchUser(c, users) })
-	r.GET("/users/popular", func(c *gin.Context) { handlers.MostPopularUsers(c, users, posts, comments) })
-
-	r.GET("/boards", func(c *gin.Context) { handlers.GetBoards(c, boards) })
-	r.POST("/boards", func(c *gin.Context) { handlers.NewBoard(c, boards) })
-	r.GET("/boards/:id", func(c *gin.Context) { handlers.GetBoard(c, boards) })
-	r.PUT("/boards/:id", func(c *gin.Context) { handlers.UpdateBoard(c, boards, users) })
-	r.DELETE("/boards/:id", func(c *gin.Context) { handlers.DeleteBoard(c, boards, users) })
-	r.GET("/boards/search", func(c *gin.Context) { handlers.SearchBoard(c, boards) })
-
-	r.POST("/boards/:id/posts", func(c *gin.Context) { handlers.NewPost(c, posts) })
-	r.GET("/boards/:id/posts/:postId", func(c *gin.Context) { handlers.GetPost(c, posts) })
-	r.GET("/boards/:id/posts", func(c *gin.Context) { handlers.GetPosts(c, posts) })
-	r.PUT("/boards/:id/posts/:postId", func(c *gin.Context) { handlers.UpdatePost(c, posts, boards, users) })
-	r.DELETE("/boards/:id/posts/:postId", func(c *gin.Context) { handlers.DeletePost(c, posts, boards, users) })
-	r.GET("/boards/:id/posts/search", func(c *gin.Context) { handlers.SearchPost(c, posts) })
-
-	r.POST("/boards/:id/posts/:postId/comments", func(c *gin.Context) { handlers.CreateComment(c, comments) })
-	r.GET("/boards/:id/posts/:postId/comments/:commentId", func(c *gin.Context) { handlers.GetComment(c, comments) })
-	r.GET("/boards/:id/posts/:postId/comments", func(c *gin.Context) { handlers.GetComments(c, comments) })
-	r.PUT("/boards/:id/posts/:postId/comments/:commentId", func(c *gin.Context) { handlers.UpdateComment(c, boards, comments) })
-	r.DELETE("/boards/:id/posts/:postId/comments/:commentId", func(c *gin.Context) { handlers.DeleteComment(c, boards, comments) })
-
-	r.POST("/export", func(c *gin.Context) { handlers.ExportToFile(c, users, boards, posts, comments) })
-	r.POST("/import", func(c *gin.Context) { handlers.ImportFromFile(c, users, boards, posts, comments) })
+func main() {
+	log.SetStyles(newStyle())
 
-	srv := &http.Server{
-		Addr:    ":8080",
-		Handler: r,
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	go handlers.Interrupt(srv, users, boards, posts, comments)
+	action := ""
+	if command == "migrate" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
 
-	cancel()
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("listen: %s\n", err)
+	cfg, printConfig, err := config.Load(os.Args[0]+" "+command, args)
+	if err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		log.Fatal("failed loading the config", "reason", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("invalid config", "reason", err)
+	}
+
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal("failed printing the config", "reason", err)
+		}
+		return
+	}
+
+	setupLog(cfg.Log)
+	log.Info("starting", "command", command)
+
+	handlers.SetTimeouts(handlers.Timeouts{
+		Query:    time.Duration(cfg.Timeouts.Query),
+		Write:    time.Duration(cfg.Timeouts.Write),
+		Reap:     time.Duration(cfg.Timeouts.Reap),
+		Shutdown: time.Duration(cfg.Timeouts.Shutdown),
+	})
+	handlers.SetBcryptCost(cfg.Security.BcryptCost)
+	types.QueryTimeout = time.Duration(cfg.Timeouts.Query)
+
+	switch command {
+	case "serve":
+		serve(cfg)
+	case "seed", "bootstrap":
+		seedCommand(cfg)
+	case "migrate":
+		migrateCommand(cfg, action)
+	default:
+		log.Fatal("unknown command, expected serve, seed or migrate", "command", command)
 	}
 }
@@ -0,0 +1,73 @@
+#!/bin/sh
+# Streams never end, so hurl can't read one while it creates what shows up in
+# it. curl holds the board stream open for a few seconds while hurl posts to
+# the board, then the frames it read are checked for the post.created event.
+set -eu
+
+HURL="${HURL:-hurl --test}"
+board=65b95156097680ef41e8fa00
+post=65b96090a21f9d310d726820
+
+frames=$(mktemp)
+trap 'rm -f "$frames"' EXIT
+
+$HURL <<EOF
+POST http://localhost:8080/sessions
+{
+    "name": "regular_user",
+    "password": "password4"
+}
+HTTP 201
+[Captures]
+regular_user_token: jsonpath "\$.token"
+
+POST http://localhost:8080/boards
+Authorization: Bearer {{regular_user_token}}
+{
+    "board": {
+        "id": "$board",
+        "name": "streaming",
+        "bio": "board about streams",
+        "moderators": [],
+        "rules": "rulez"
+    }
+}
+HTTP 201
+EOF
+
+# curl gives up with 28 once the time is up, that's how the stream ends
+curl -sN --max-time 3 "http://localhost:8080/boards/$board/stream" > "$frames" || [ $? -eq 28 ] &
+reader=$!
+sleep 1
+
+$HURL <<EOF
+POST http://localhost:8080/sessions
+{
+    "name": "regular_user",
+    "password": "password4"
+}
+HTTP 201
+[Captures]
+regular_user_token: jsonpath "\$.token"
+
+POST http://localhost:8080/boards/$board/posts
+Authorization: Bearer {{regular_user_token}}
+{
+    "post": {
+        "id": "$post",
+        "title": "live",
+        "bodyType": 0,
+        "bodyContent": "streamed"
+    }
+}
+HTTP 201
+EOF
+
+wait $reader
+
+if ! grep -q '^event: post.created$' "$frames" || ! grep -q "^data: .*\"id\":\"$post\"" "$frames"; then
+    echo "expected a post.created frame for $post, the stream sent:" >&2
+    cat "$frames" >&2
+    exit 1
+fi
+echo "stream sent post.created"
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AccessTokenTTL  = time.Minute * 15
+	RefreshTokenTTL = time.Hour * 24 * 7
+)
+
+var (
+	ErrMalformedToken = errors.New("malformed token")
+	ErrBadSignature   = errors.New("token signature mismatch")
+	ErrExpiredToken   = errors.New("token expired")
+	ErrMissingSecret  = errors.New("signing secret not set")
+)
+
+var secret []byte
+
+type Claims struct {
+	Session primitive.ObjectID `json:"sid"`
+	User    primitive.ObjectID `json:"uid"`
+	Expires int64              `json:"exp"`
+}
+
+func SetSecret(s []byte) {
+	secret = s
+}
+
+// RandomSecret is used when no secret is configured, tokens won't survive a
+// restart and won't be accepted by other replicas.
+func RandomSecret() ([]byte, error) {
+	s := make([]byte, 32)
+	_, err := rand.Read(s)
+	return s, err
+}
+
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func NewAccessToken(session, user primitive.ObjectID) (string, time.Time, error) {
+	if len(secret) == 0 {
+		return "", time.Time{}, ErrMissingSecret
+	}
+
+	expires := time.Now().Add(AccessTokenTTL)
+	raw, err := json.Marshal(Claims{
+		Session: session,
+		User:    user,
+		Expires: expires.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return payload + "." + sign(payload), expires, nil
+}
+
+func Verify(token string) (Claims, error) {
+	if len(secret) == 0 {
+		return Claims{}, ErrMissingSecret
+	}
+
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Claims{}, ErrMalformedToken
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(sign(payload))) {
+		return Claims{}, ErrBadSignature
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Claims{}, ErrMalformedToken
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return Claims{}, ErrMalformedToken
+	}
+
+	if time.Now().Unix() >= claims.Expires {
+		return Claims{}, ErrExpiredToken
+	}
+
+	return claims, nil
+}
+
+// NewRefreshToken returns the token handed to the client and the hash that
+// gets stored, so a leaked sessions collection can't be used to refresh.
+func NewRefreshToken() (token string, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err = rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(raw)
+	return token, HashRefreshToken(token), nil
+}
+
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,406 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	StorageMongo  = "mongo"
+	StorageMemory = "memory"
+)
+
+const (
+	MediaFiles  = "files"
+	MediaGridFS = "gridfs"
+)
+
+const (
+	FanoutLocal = "local"
+	FanoutMongo = "mongo"
+)
+
+const redacted = "********"
+
+// Duration reads and writes as a Go duration string like "200ms" in both
+// YAML and TOML.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+type Config struct {
+	Storage   string    `yaml:"storage" toml:"storage"`
+	Database  Database  `yaml:"database" toml:"database"`
+	Server    Server    `yaml:"server" toml:"server"`
+	Timeouts  Timeouts  `yaml:"timeouts" toml:"timeouts"`
+	Security  Security  `yaml:"security" toml:"security"`
+	Log       Log       `yaml:"log" toml:"log"`
+	Features  Features  `yaml:"features" toml:"features"`
+	Bootstrap Bootstrap `yaml:"bootstrap" toml:"bootstrap"`
+	Media     Media     `yaml:"media" toml:"media"`
+	Live      Live      `yaml:"live" toml:"live"`
+}
+
+type Database struct {
+	URI      string `yaml:"uri" toml:"uri"`
+	Name     string `yaml:"name" toml:"name"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+type Server struct {
+	Addr    string `yaml:"addr" toml:"addr"`
+	TLSCert string `yaml:"tlsCert" toml:"tlsCert"`
+	TLSKey  string `yaml:"tlsKey" toml:"tlsKey"`
+}
+
+// Timeouts bound how long the server waits on the database. Query covers
+// single reads and writes, Write the cascading ones like deletes, restores
+// and backups, Migrate the schema migrations including the wait for another
+// replica running them.
+type Timeouts struct {
+	Connect  Duration `yaml:"connect" toml:"connect"`
+	Query    Duration `yaml:"query" toml:"query"`
+	Write    Duration `yaml:"write" toml:"write"`
+	Reap     Duration `yaml:"reap" toml:"reap"`
+	Shutdown Duration `yaml:"shutdown" toml:"shutdown"`
+	Migrate  Duration `yaml:"migrate" toml:"migrate"`
+}
+
+type Security struct {
+	BcryptCost int `yaml:"bcryptCost" toml:"bcryptCost"`
+	// Secret signs the session tokens, a random one is generated when empty.
+	Secret string `yaml:"secret" toml:"secret"`
+}
+
+type Log struct {
+	Level  string `yaml:"level" toml:"level"`
+	Format string `yaml:"format" toml:"format"`
+}
+
+type Features struct {
+	Registration bool `yaml:"registration" toml:"registration"`
+	Backup       bool `yaml:"backup" toml:"backup"`
+	Reaper       bool `yaml:"reaper" toml:"reaper"`
+	// Migrate applies pending schema migrations on start, without it the
+	// migrate command has to.
+	Migrate bool `yaml:"migrate" toml:"migrate"`
+}
+
+// Bootstrap is what the seed command creates, the server seeds it on start
+// only with memory storage.
+type Bootstrap struct {
+	AdminName     string `yaml:"adminName" toml:"adminName"`
+	AdminEmail    string `yaml:"adminEmail" toml:"adminEmail"`
+	AdminPassword string `yaml:"adminPassword" toml:"adminPassword"`
+	// Fixtures is a directory of JSON files shaped like the request bodies
+	// in requests/ or like an export.
+	Fixtures string `yaml:"fixtures" toml:"fixtures"`
+}
+
+// Media is where uploaded images go, a directory or a GridFS bucket in the
+// mongo database.
+type Media struct {
+	Store string `yaml:"store" toml:"store"`
+	Dir   string `yaml:"dir" toml:"dir"`
+}
+
+// Live is how streamed events reach the clients, local only reaches the
+// ones connected to the same replica, mongo relays them through a change
+// stream to every replica.
+type Live struct {
+	Fanout string `yaml:"fanout" toml:"fanout"`
+}
+
+func Default() Config {
+	return Config{
+		Storage: StorageMongo,
+		Database: Database{
+			URI:      "mongodb://localhost:27017/?directConnection=true",
+			Name:     "redoot",
+			Username: "root",
+			Password: "example",
+		},
+		Server: Server{
+			Addr: ":8080",
+		},
+		Timeouts: Timeouts{
+			Connect:  Duration(time.Millisecond * 200),
+			Query:    Duration(time.Millisecond * 200),
+			Write:    Duration(time.Second),
+			Reap:     Duration(time.Second * 5),
+			Shutdown: Duration(time.Second * 5),
+			Migrate:  Duration(time.Minute * 5),
+		},
+		Security: Security{
+			BcryptCost: bcrypt.MinCost,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "text",
+		},
+		Features: Features{
+			Registration: true,
+			Backup:       true,
+			Reaper:       true,
+			Migrate:      true,
+		},
+		Media: Media{
+			Store: MediaFiles,
+			Dir:   "media",
+		},
+		Live: Live{
+			Fanout: FanoutLocal,
+		},
+	}
+}
+
+// setting is one option that can be overridden from the environment and,
+// when flag is set, from the command line.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	set   func(*Config, string) error
+	// boolean flags can be passed without a value
+	boolean bool
+}
+
+func text(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func number(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+func duration(field func(*Config) *Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		return field(c).UnmarshalText([]byte(value))
+	}
+}
+
+func toggle(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+var settings = []setting{
+	{flag: "storage", env: "REDOOT_STORAGE", usage: "where to keep the data, mongo or memory", set: text(func(c *Config) *string { return &c.Storage })},
+	{flag: "db-uri", env: "REDOOT_DB_URI", usage: "mongo connection string", set: text(func(c *Config) *string { return &c.Database.URI })},
+	{flag: "db-name", env: "REDOOT_DB_NAME", usage: "mongo database name", set: text(func(c *Config) *string { return &c.Database.Name })},
+	{flag: "db-user", env: "REDOOT_DB_USER", usage: "mongo username", set: text(func(c *Config) *string { return &c.Database.Username })},
+	{env: "REDOOT_DB_PASSWORD", set: text(func(c *Config) *string { return &c.Database.Password })},
+	{flag: "addr", env: "REDOOT_ADDR", usage: "address to listen on", set: text(func(c *Config) *string { return &c.Server.Addr })},
+	{flag: "tls-cert", env: "REDOOT_TLS_CERT", usage: "TLS certificate file", set: text(func(c *Config) *string { return &c.Server.TLSCert })},
+	{flag: "tls-key", env: "REDOOT_TLS_KEY", usage: "TLS key file", set: text(func(c *Config) *string { return &c.Server.TLSKey })},
+	{flag: "timeout-connect", env: "REDOOT_TIMEOUT_CONNECT", usage: "timeout for connecting to the database", set: duration(func(c *Config) *Duration { return &c.Timeouts.Connect })},
+	{flag: "timeout-query", env: "REDOOT_TIMEOUT_QUERY", usage: "timeout for single reads and writes", set: duration(func(c *Config) *Duration { return &c.Timeouts.Query })},
+	{flag: "timeout-write", env: "REDOOT_TIMEOUT_WRITE", usage: "timeout for deletes, restores and backups", set: duration(func(c *Config) *Duration { return &c.Timeouts.Write })},
+	{flag: "timeout-reap", env: "REDOOT_TIMEOUT_REAP", usage: "timeout for purging the trash", set: duration(func(c *Config) *Duration { return &c.Timeouts.Reap })},
+	{flag: "timeout-shutdown", env: "REDOOT_TIMEOUT_SHUTDOWN", usage: "timeout for the graceful shutdown", set: duration(func(c *Config) *Duration { return &c.Timeouts.Shutdown })},
+	{flag: "timeout-migrate", env: "REDOOT_TIMEOUT_MIGRATE", usage: "timeout for schema migrations", set: duration(func(c *Config) *Duration { return &c.Timeouts.Migrate })},
+	{flag: "bcrypt-cost", env: "REDOOT_BCRYPT_COST", usage: "bcrypt cost of password hashes", set: number(func(c *Config) *int { return &c.Security.BcryptCost })},
+	{env: "REDOOT_SECRET", set: text(func(c *Config) *string { return &c.Security.Secret })},
+	{flag: "log-level", env: "LOG", usage: "debug, info, warn, error or fatal", set: text(func(c *Config) *string { return &c.Log.Level })},
+	{flag: "log-format", env: "REDOOT_LOG_FORMAT", usage: "text, json or logfmt", set: text(func(c *Config) *string { return &c.Log.Format })},
+	{flag: "registration", env: "REDOOT_REGISTRATION", usage: "allow signing up through POST /users", set: toggle(func(c *Config) *bool { return &c.Features.Registration }), boolean: true},
+	{flag: "backup", env: "REDOOT_BACKUP", usage: "enable the export and import endpoints", set: toggle(func(c *Config) *bool { return &c.Features.Backup }), boolean: true},
+	{flag: "reaper", env: "REDOOT_REAPER", usage: "purge the trash periodically", set: toggle(func(c *Config) *bool { return &c.Features.Reaper }), boolean: true},
+	{flag: "migrate", env: "REDOOT_MIGRATE", usage: "apply pending schema migrations on start", set: toggle(func(c *Config) *bool { return &c.Features.Migrate }), boolean: true},
+	{flag: "admin-name", env: "REDOOT_ADMIN_NAME", usage: "name of the admin to bootstrap", set: text(func(c *Config) *string { return &c.Bootstrap.AdminName })},
+	{flag: "admin-email", env: "REDOOT_ADMIN_EMAIL", usage: "email of the admin to bootstrap", set: text(func(c *Config) *string { return &c.Bootstrap.AdminEmail })},
+	{env: "REDOOT_ADMIN_PASSWORD", set: text(func(c *Config) *string { return &c.Bootstrap.AdminPassword })},
+	{flag: "fixtures", env: "REDOOT_FIXTURES", usage: "directory of JSON fixtures to seed", set: text(func(c *Config) *string { return &c.Bootstrap.Fixtures })},
+	{flag: "media-store", env: "REDOOT_MEDIA_STORE", usage: "where to keep uploads, files or gridfs", set: text(func(c *Config) *string { return &c.Media.Store })},
+	{flag: "media-dir", env: "REDOOT_MEDIA_DIR", usage: "directory of the files media store", set: text(func(c *Config) *string { return &c.Media.Dir })},
+	{flag: "live-fanout", env: "REDOOT_LIVE_FANOUT", usage: "how streamed events reach every replica, local or mongo", set: text(func(c *Config) *string { return &c.Live.Fanout })},
+}
+
+// Load builds the configuration from the defaults, the config file, the
+// environment and the flags, each overriding the one before. printConfig
+// reports whether --print-config was passed.
+func Load(name string, args []string) (cfg Config, printConfig bool, err error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("REDOOT_CONFIG"), "YAML or TOML config file")
+	fs.BoolVar(&printConfig, "print-config", false, "print the effective config and exit")
+	for _, s := range settings {
+		if s.boolean {
+			fs.Bool(s.flag, false, s.usage)
+		} else if s.flag != "" {
+			fs.String(s.flag, "", s.usage)
+		}
+	}
+
+	err = fs.Parse(args)
+	if err != nil {
+		return cfg, false, err
+	}
+
+	cfg = Default()
+	if *path != "" {
+		err = readFile(*path, &cfg)
+		if err != nil {
+			return cfg, false, err
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.env)
+		if !ok {
+			continue
+		}
+		if err := s.set(&cfg, value); err != nil {
+			return cfg, false, fmt.Errorf("%s: %w", s.env, err)
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag != f.Name || err != nil {
+				continue
+			}
+			if setErr := s.set(&cfg, f.Value.String()); setErr != nil {
+				err = fmt.Errorf("--%s: %w", f.Name, setErr)
+			}
+		}
+	})
+
+	return cfg, printConfig, err
+}
+
+func readFile(path string, cfg *Config) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, cfg)
+	case ".toml":
+		err = toml.Unmarshal(raw, cfg)
+	default:
+		return fmt.Errorf("%s: config file must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every problem with the configuration at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Storage == StorageMongo || c.Storage == StorageMemory, "storage must be mongo or memory, got %q", c.Storage)
+	if c.Storage == StorageMongo {
+		check(c.Database.URI != "", "database uri is required with mongo storage")
+		check(c.Database.Name != "", "database name is required with mongo storage")
+	}
+
+	check(c.Server.Addr != "", "server addr is required")
+	check((c.Server.TLSCert == "") == (c.Server.TLSKey == ""), "tls cert and key have to be set together")
+	for _, file := range []string{c.Server.TLSCert, c.Server.TLSKey} {
+		if file == "" {
+			continue
+		}
+		_, err := os.Stat(file)
+		check(err == nil, "tls file: %v", err)
+	}
+
+	check(c.Timeouts.Connect > 0, "connect timeout must be positive")
+	check(c.Timeouts.Query > 0, "query timeout must be positive")
+	check(c.Timeouts.Write > 0, "write timeout must be positive")
+	check(c.Timeouts.Reap > 0, "reap timeout must be positive")
+	check(c.Timeouts.Shutdown > 0, "shutdown timeout must be positive")
+	check(c.Timeouts.Migrate > 0, "migrate timeout must be positive")
+
+	check(c.Security.BcryptCost >= bcrypt.MinCost && c.Security.BcryptCost <= bcrypt.MaxCost,
+		"bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+
+	if c.Bootstrap.Fixtures != "" {
+		info, err := os.Stat(c.Bootstrap.Fixtures)
+		check(err == nil && info.IsDir(), "fixtures must be a directory: %s", c.Bootstrap.Fixtures)
+	}
+
+	check(c.Media.Store == MediaFiles || c.Media.Store == MediaGridFS, "media store must be files or gridfs, got %q", c.Media.Store)
+	check(c.Media.Store != MediaFiles || c.Media.Dir != "", "media dir is required with the files media store")
+	check(c.Media.Store != MediaGridFS || c.Storage == StorageMongo, "the gridfs media store needs mongo storage")
+
+	check(c.Live.Fanout == FanoutLocal || c.Live.Fanout == FanoutMongo, "live fanout must be local or mongo, got %q", c.Live.Fanout)
+	check(c.Live.Fanout != FanoutMongo || c.Storage == StorageMongo, "the mongo live fanout needs mongo storage")
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error", "fatal":
+	default:
+		check(false, "log level must be one of debug, info, warn, error, fatal, got %q", c.Log.Level)
+	}
+	switch c.Log.Format {
+	case "text", "json", "logfmt":
+	default:
+		check(false, "log format must be one of text, json, logfmt, got %q", c.Log.Format)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Print writes the configuration as YAML with the secrets masked.
+func (c Config) Print(w io.Writer) error {
+	if c.Database.Password != "" {
+		c.Database.Password = redacted
+	}
+	if c.Security.Secret != "" {
+		c.Security.Secret = redacted
+	}
+	if c.Bootstrap.AdminPassword != "" {
+		c.Bootstrap.AdminPassword = redacted
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(c)
+}
@@ -0,0 +1,178 @@
+// Package content checks the body of a post against its type and brings it
+// into the form it's stored in.
+package content
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net"
+	"net/url"
+	"redoot/internal/types"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MaxTitleLength = 300
+	MaxTextLength  = 40000
+	MaxLinkLength  = 2048
+	// MaxImageBytes bounds the decoded image, MaxImageDimension both of its
+	// sides so decoding it stays cheap.
+	MaxImageBytes     = 5 << 20
+	MaxImageDimension = 4096
+)
+
+// ImageFormats are the formats image posts can use.
+var ImageFormats = []string{"png", "jpeg", "gif"}
+
+var (
+	ErrTitle           = fmt.Errorf("title must be between 1 and %d characters", MaxTitleLength)
+	ErrText            = fmt.Errorf("text must be at most %d characters", MaxTextLength)
+	ErrLink            = errors.New("link must be an absolute http or https URL without credentials")
+	ErrLinkLength      = fmt.Errorf("link must be at most %d characters", MaxLinkLength)
+	ErrImage           = errors.New("image must be a base64 encoded png, jpeg or gif")
+	ErrImageSize       = fmt.Errorf("image must be at most %d bytes", MaxImageBytes)
+	ErrImageDimensions = fmt.Errorf("image sides must be between 1 and %d pixels", MaxImageDimension)
+	ErrBodyType        = errors.New("body type must be 0 for text, 1 for an image or 2 for a link")
+)
+
+// trackingParams are dropped from links so the same page posted from
+// different campaigns is still recognized as a duplicate.
+var trackingParams = []string{"fbclid", "gclid"}
+
+// Normalize validates the title and the body of the post for its type and
+// rewrites them into their canonical form. The body of image posts is only
+// trimmed, the media library turns it into stored media.
+func Normalize(post *types.Post) error {
+	post.Title = strings.TrimSpace(post.Title)
+	if length := utf8.RuneCountInString(post.Title); length < 1 || length > MaxTitleLength {
+		return ErrTitle
+	}
+
+	post.Image = nil
+	switch post.BodyType {
+	case types.Text:
+		if utf8.RuneCountInString(post.BodyContent) > MaxTextLength {
+			return ErrText
+		}
+	case types.Link:
+		link, err := CanonicalLink(post.BodyContent)
+		if err != nil {
+			return err
+		}
+		post.BodyContent = link
+	case types.Image:
+		post.BodyContent = strings.TrimSpace(post.BodyContent)
+	default:
+		return ErrBodyType
+	}
+	return nil
+}
+
+// CanonicalLink lower cases the scheme and host, drops default ports,
+// fragments and tracking parameters and sorts the query.
+func CanonicalLink(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) > MaxLinkLength {
+		return "", ErrLinkLength
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.User != nil || u.Hostname() == "" {
+		return "", ErrLink
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	port := u.Port()
+	switch {
+	case u.Scheme == "http" && port == "80", u.Scheme == "https" && port == "443":
+		port = ""
+	case u.Scheme != "http" && u.Scheme != "https":
+		return "", ErrLink
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment, u.RawFragment = "", ""
+
+	query := u.Query()
+	for key := range query {
+		if strings.HasPrefix(key, "utm_") || slices.Contains(trackingParams, key) {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode()
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
+
+// Image decodes a base64 image, optionally as a data URI, and checks its
+// format, size and dimensions.
+func Image(encoded string) ([]byte, types.ImageInfo, error) {
+	encoded = strings.TrimSpace(encoded)
+	if strings.HasPrefix(encoded, "data:") {
+		_, after, ok := strings.Cut(encoded, ";base64,")
+		if !ok {
+			return nil, types.ImageInfo{}, ErrImage
+		}
+		encoded = after
+	}
+
+	if base64.StdEncoding.DecodedLen(len(encoded)) > MaxImageBytes+2 {
+		return nil, types.ImageInfo{}, ErrImageSize
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, types.ImageInfo{}, ErrImage
+	}
+	if len(data) > MaxImageBytes {
+		return nil, types.ImageInfo{}, ErrImageSize
+	}
+
+	info, err := ImageInfo(data)
+	return data, info, err
+}
+
+// ImageInfo checks the image is in one of the formats, within the limits and
+// decodes completely.
+func ImageInfo(data []byte) (types.ImageInfo, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || !slices.Contains(ImageFormats, format) {
+		return types.ImageInfo{}, ErrImage
+	}
+
+	if config.Width < 1 || config.Height < 1 || config.Width > MaxImageDimension || config.Height > MaxImageDimension {
+		return types.ImageInfo{}, ErrImageDimensions
+	}
+
+	// the header alone could belong to a truncated or corrupt file
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		return types.ImageInfo{}, ErrImage
+	}
+
+	return types.ImageInfo{
+		Format: format,
+		Width:  config.Width,
+		Height: config.Height,
+		Size:   len(data),
+	}, nil
+}
@@ -0,0 +1,101 @@
+// Package diff compares two versions of a text line by line, revisions keep
+// the result so an edit can be reviewed without both versions at hand.
+package diff
+
+import "strings"
+
+// maxCells bounds the table the longest common subsequence is searched in,
+// bigger changes are shown as everything removed and everything added.
+const maxCells = 1 << 22
+
+// Lines returns the lines of before and after prefixed with "-" when they
+// were removed, "+" when they were added and " " when they stayed.
+func Lines(before, after string) string {
+	if before == after {
+		return ""
+	}
+	a, b := split(before), split(after)
+
+	// the common ends need no table
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		start++
+	}
+	end := 0
+	for end < len(a)-start && end < len(b)-start && a[len(a)-1-end] == b[len(b)-1-end] {
+		end++
+	}
+
+	var out strings.Builder
+	for _, line := range a[:start] {
+		write(&out, ' ', line)
+	}
+	middle(&out, a[start:len(a)-end], b[start:len(b)-end])
+	for _, line := range a[len(a)-end:] {
+		write(&out, ' ', line)
+	}
+	return out.String()
+}
+
+func split(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+func write(out *strings.Builder, mark byte, line string) {
+	out.WriteByte(mark)
+	out.WriteString(line)
+	out.WriteByte('\n')
+}
+
+// middle diffs the part between the common ends through the longest common
+// subsequence of its lines.
+func middle(out *strings.Builder, a, b []string) {
+	if len(a)*len(b) > maxCells {
+		for _, line := range a {
+			write(out, '-', line)
+		}
+		for _, line := range b {
+			write(out, '+', line)
+		}
+		return
+	}
+
+	// lcs[i][j] is the length of the common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			write(out, ' ', a[i])
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			write(out, '-', a[i])
+			i++
+		default:
+			write(out, '+', b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		write(out, '-', a[i])
+	}
+	for ; j < len(b); j++ {
+		write(out, '+', b[j])
+	}
+}
@@ -6,29 +6,35 @@ import (
 	"fmt"
 	"net/http"
 	"redoot/internal/msgs"
+	"redoot/internal/storage"
 	"redoot/internal/types"
+	"slices"
+	"strings"
 	"sync"
-	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func NewBoard(c *gin.Context, boards *mongo.Collection) {
+func NewBoard(c *gin.Context, boards storage.BoardStore) {
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
 	body := struct {
-		Board     types.Board       `json:"board"`
-		Requester types.Credentials `json:"requester"`
+		Board types.Board `json:"board"`
 	}{}
 
-	err := decodeBody(c, &body)
+	err = decodeBody(c, &body)
 	if err != nil {
 		return
 	}
 
 	board := body.Board
+	board.Owner = usr.ID
+	board.Deletion = types.Deletion{}
 	log.Debug(msgs.DebugStruct, "board", fmt.Sprintf("%#v", board))
 
 	if log.GetLevel() == log.DebugLevel {
@@ -36,43 +42,15 @@ func NewBoard(c *gin.Context, boards *mongo.Collection) {
 		log.Debug(msgs.DebugJSON, "board", string(debugJSON))
 	}
 
-	err = body.Requester.Authorize()
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotAuthorized,
-			"user wasn't authorized",
-			"error", err,
-		))
-		return
-	}
-
-	usr, err := body.Requester.ToUser()
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"skill issue",
-			"error", err,
-		))
-		return
-	}
-
-	if body.Board.Owner != usr.ID {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrForbidden,
-			"can't create board for someone else",
-		))
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
 	defer cancel()
 
-	result, optionsErr := boards.InsertOne(ctx, board)
-	if optionsErr != nil {
+	id, err := boards.Create(ctx, board)
+	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrBadOptions,
-			"Bad options provided in the InsertOne",
-			optionsErr,
+			"failed creating the board",
+			"NewBoard", err,
 		))
 		return
 	}
@@ -83,54 +61,45 @@ func NewBoard(c *gin.Context, boards *mongo.Collection) {
 	}{
 		Code:   http.StatusCreated,
 		Status: "OK",
-		ID:     result.InsertedID.(primitive.ObjectID).String(),
+		ID:     id.String(),
 	})
 }
 
-func GetBoards(c *gin.Context, boardsColl *mongo.Collection) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
-
-	cursor, err := boardsColl.Find(ctx, bson.M{})
+func GetBoards(c *gin.Context, boardsStore storage.BoardStore) {
+	p, err := pageParams(c, sortNew)
 	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"Bad options provided for Find",
-			"reason", "bad options provided for GetBoards",
-		))
 		return
 	}
 
-	var boards []types.Board
-	err = cursor.All(ctx, &boards)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	boards, err := boardsStore.List(ctx, p.query())
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
-			"Failed decoding cursor",
-			"GetBoards cursor", err,
+			"failed listing boards",
+			"GetBoards", err,
 		))
 		return
 	}
 	log.Debug(msgs.DebugStruct, "users", fmt.Sprintf("%#v\n", boards))
-	c.JSON(http.StatusOK, boards)
+	respondPage(c, p, boards, func(b types.Board) (float64, primitive.ObjectID) {
+		return 0, b.ID
+	})
 }
 
-func GetBoard(c *gin.Context, boards *mongo.Collection) {
+func GetBoard(c *gin.Context, boards storage.BoardStore) {
 	objid, err := idFromParams(c)
 	if err != nil {
 		return
 	}
 
-	filter := bson.M{"_id": objid}
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
 	defer cancel()
 
-	result := boards.FindOne(ctx, filter)
-
-	var board types.Board
-	err = result.Decode(&board)
-	if err == mongo.ErrNoDocuments {
+	board, err := boards.Get(ctx, objid)
+	if err == storage.ErrNotFound {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrNotFound,
 			"board not found",
@@ -150,23 +119,29 @@ func GetBoard(c *gin.Context, boards *mongo.Collection) {
 	c.JSON(http.StatusOK, board)
 }
 
-func UpdateBoard(c *gin.Context, boards *mongo.Collection, users *mongo.Collection) {
+func UpdateBoard(c *gin.Context, boards storage.BoardStore) {
 	objid, err := idFromParams(c)
 	if err != nil {
 		return
 	}
 
+	user, err := requester(c)
+	if err != nil {
+		return
+	}
+
 	var bdy struct {
-		Board     types.Board       `json:"board"`
-		Requester types.Credentials `json:"requester"`
+		Board types.Board `json:"board"`
 	}
 	err = decodeBody(c, &bdy)
 	if err != nil {
 		return
 	}
 
-	var board types.Board
-	err = getAndConvert(boards, objid, &board)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	board, err := boards.Get(ctx, objid)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrNotFound,
@@ -175,52 +150,41 @@ func UpdateBoard(c *gin.Context, boards *mongo.Collection, users *mongo.Collecti
 		return
 	}
 
-	if err := bdy.Requester.Authorize(); err != nil {
+	admin := types.IsAdmin(user)
+	if !(admin || types.IsModerator(board, user) || board.Owner == user.ID) {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotAuthorized,
-			"user not authorized",
-		))
-		return
-	}
-
-	user, err := bdy.Requester.ToUser()
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"failed getting user",
+			msgs.ErrForbidden,
+			"action is forbidden!",
+			"UpdateBoard", "is neither an admin, moderator nor owner",
 		))
 		return
 	}
 
-	if !(types.IsAdmin(user) || types.IsModerator(board, user) || board.Owner == user.ID) {
+	if !slices.Equal(bdy.Board.Moderators, board.Moderators) && !(admin || board.Owner == user.ID) {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrForbidden,
-			"action is forbidden!",
-			"UpdateBoard", "is neither an admin, moderator nor owner",
+			"only the owner picks the moderators",
+			"UpdateBoard", "is neither an admin nor owner",
 		))
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
-
-	update := bson.M{"$set": bdy.Board}
+	bdy.Board.Owner = board.Owner
+	bdy.Board.Deletion = types.Deletion{}
 
-	updateResult, err := boards.UpdateByID(ctx, objid, update)
-	if err != nil {
+	err = boards.Update(ctx, objid, bdy.Board)
+	if err == storage.ErrNotFound || err == storage.ErrNotModified {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrBadOptions,
-			"options failure",
+			msgs.ErrUpdateFailed,
+			"failed to update the board",
 			"UpdateBoard", err,
 		))
 		return
-	}
-
-	if updateResult.ModifiedCount == 0 {
+	} else if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrUpdateFailed,
-			"failed to update the board",
-			"UpdateBoard", updateResult,
+			msgs.ErrBadOptions,
+			"options failure",
+			"UpdateBoard", err,
 		))
 		return
 	}
@@ -233,75 +197,131 @@ func UpdateBoard(c *gin.Context, boards *mongo.Collection, users *mongo.Collecti
 	})
 }
 
-func DeleteBoard(c *gin.Context, boards *mongo.Collection, users *mongo.Collection) {
+// PatchBoard changes the fields of the board in the merge patch body and
+// answers with the board, only the owner and admins pick the moderators.
+func PatchBoard(c *gin.Context, boards storage.BoardStore) {
 	objid, err := idFromParams(c)
 	if err != nil {
 		return
 	}
 
-	body := struct {
-		Requester types.Credentials `json:"requester"`
-	}{}
+	user, err := requester(c)
+	if err != nil {
+		return
+	}
 
-	err = decodeBody(c, &body)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	board, err := boards.Get(ctx, objid)
 	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"not found",
+		))
 		return
 	}
 
-	if err := body.Requester.Authorize(); err != nil {
+	admin := types.IsAdmin(user)
+	if !(admin || types.IsModerator(board, user) || board.Owner == user.ID) {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotAuthorized,
-			"user not authorized",
+			msgs.ErrForbidden,
+			"action is forbidden!",
+			"PatchBoard", "is neither an admin, moderator nor owner",
 		))
 		return
 	}
 
-	var board types.Board
-	err = getAndConvert(boards, objid, &board)
-	if err != nil {
+	patched := board
+	touched, ok := mergePatch(c, patchable{
+		"name":       &patched.Name,
+		"bio":        &patched.Bio,
+		"rules":      &patched.Rules,
+		"moderators": &patched.Moderators,
+	})
+	if !ok {
+		return
+	}
+
+	if slices.Contains(touched, "moderators") && !(admin || board.Owner == user.ID) {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotFound,
-			"board finding skill issue",
+			msgs.ErrForbidden,
+			"only the owner picks the moderators",
+			"PatchBoard", "is neither an admin nor owner",
 		))
 		return
 	}
 
-	usr, err := body.Requester.ToUser()
-	if err != nil {
+	if strings.TrimSpace(patched.Name) == "" {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotFound,
-			"user finding skill issue",
+			msgs.ErrWrongFormat,
+			"name can't be empty",
 		))
 		return
 	}
 
-	if !(types.IsAdmin(usr) || types.IsModerator(board, usr) || board.Owner == usr.ID) {
+	err = boards.Update(ctx, objid, patched)
+	if err != nil && err != storage.ErrNotModified {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrForbidden,
-			"action is forbidden!",
-			"DeleteBoard", "is neither an admin, moderator nor owner",
+			msgs.ErrBadOptions,
+			"options failure",
+			"PatchBoard", err,
 		))
 		return
 	}
+	// reread for the updatedAt the store stamped
+	if stored, err := boards.Get(ctx, objid); err == nil {
+		patched = stored
+	}
+
+	c.JSON(http.StatusOK, patched)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+func DeleteBoard(c *gin.Context, boards storage.BoardStore) {
+	objid, err := idFromParams(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Write)
 	defer cancel()
 
-	deleteResult, err := boards.DeleteOne(ctx, bson.M{"_id": objid})
+	board, err := boards.Get(ctx, objid)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrBadOptions,
-			"internal error",
-			"DeleteBoard", err,
+			msgs.ErrNotFound,
+			"board finding skill issue",
 		))
 		return
 	}
 
-	if deleteResult.DeletedCount != 1 {
+	if !(types.IsAdmin(usr) || types.IsModerator(board, usr) || board.Owner == usr.ID) {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrForbidden,
+			"action is forbidden!",
+			"DeleteBoard", "is neither an admin, moderator nor owner",
+		))
+		return
+	}
+
+	err = boards.SoftDelete(ctx, objid, usr.ID)
+	if err == storage.ErrNotFound {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrNotFound,
 			"board failed to delete",
-			"DeleteUser", deleteResult.DeletedCount != 1,
+			"DeleteBoard", err,
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed deleting the board",
+			"DeleteBoard", err,
 		))
 		return
 	}
@@ -315,19 +335,24 @@ func DeleteBoard(c *gin.Context, boards *mongo.Collection, users *mongo.Collecti
 	})
 }
 
-func SearchBoard(c *gin.Context, boards *mongo.Collection) {
+func SearchBoard(c *gin.Context, boards storage.BoardStore) {
+	query, err := searchParams(c, boardSearchFields)
+	if err != nil {
+		return
+	}
+
 	var length int
-	for _, v := range c.Request.URL.Query() {
+	for _, v := range query {
 		length += len(v)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
 	defer cancel()
 
 	var wg sync.WaitGroup
 	ch := make(chan findResultBoards, length)
 
-	for k, s := range c.Request.URL.Query() {
+	for k, s := range query {
 		for _, v := range s {
 			wg.Add(1)
 			go findByFieldBoards(ctx, boards, k, v, ch, &wg)
@@ -347,6 +372,7 @@ func SearchBoard(c *gin.Context, boards *mongo.Collection) {
 		values = append(values, v.boards...)
 	}
 
+	values = unique(values, func(v types.Board) primitive.ObjectID { return v.ID })
 	if len(values) == 0 {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrNotFound,
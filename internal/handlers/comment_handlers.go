@@ -4,67 +4,108 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"redoot/internal/live"
 	"redoot/internal/msgs"
+	"redoot/internal/notify"
+	"redoot/internal/storage"
 	"redoot/internal/types"
-	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func CreateComment(c *gin.Context, comments *mongo.Collection) {
+func CreateComment(c *gin.Context, comments storage.CommentStore, posts storage.PostStore, users storage.UserStore, boards storage.BoardStore, notifier *notify.Notifier, events live.Publisher) {
+	boardId, postId, err := postId(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
 	var body struct {
-		Comment   types.Comment     `json:"comment"`
-		Requester types.Credentials `json:"requester"`
+		Comment types.Comment `json:"comment"`
 	}
-	err := decodeBody(c, &body)
+	err = decodeBody(c, &body)
 	if err != nil {
 		return
 	}
 
-	log.Debug(msgs.DebugStruct, "requester", body.Requester)
-	if err := body.Requester.Authorize(); err != nil {
+	if !body.Comment.Post.IsZero() && body.Comment.Post != postId {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotAuthorized,
-			"user not authorized",
-			"error", err,
+			msgs.ErrWrongFormat,
+			"comment post doesn't match the post in the path",
 		))
 		return
 	}
+	body.Comment.Post = postId
+	body.Comment.Author = usr.ID
+	body.Comment.Votes, body.Comment.Ups, body.Comment.Downs, body.Comment.Controversy = 0, 0, 0, 0
+	body.Comment.Deletion = types.Deletion{}
+	body.Comment.Edit = types.Edit{}
 
-	usr, err := body.Requester.ToUser()
-	if err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	post, err := posts.Get(ctx, boardId, postId)
+	if err == storage.ErrNotFound {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"skill issue",
-			"error", err,
+			msgs.ErrNotFound,
+			"post not found!",
 		))
 		return
-	}
-
-	if body.Comment.Author != usr.ID {
+	} else if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrForbidden,
-			"can't create comment for someone else",
+			msgs.ErrInternal,
+			"an internal error has accured",
+			"CreateComment", err,
 		))
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
+	var parent *types.Comment
+	if body.Comment.Parent != nil {
+		found, err := comments.GetAny(ctx, postId, *body.Comment.Parent)
+		if err == storage.ErrNotFound || (err == nil && found.DeletedAt != nil) {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrNotFound,
+				"parent comment not found",
+			))
+			return
+		} else if err != nil {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrInternal,
+				"failed getting parent comment",
+				"CreateComment", err,
+			))
+			return
+		}
+		parent = &found
+	}
+
+	rendered, mentioned, ok := renderMarkdown(ctx, c, users, boards, body.Comment.Body)
+	if !ok {
+		return
+	}
+	body.Comment.BodyHTML = rendered
 
-	result, err := comments.InsertOne(ctx, body.Comment)
+	id, err := comments.Create(ctx, body.Comment)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrBadOptions,
-			"Bad options provided in the InsertOne",
-			err,
+			"failed creating the comment",
+			"CreateComment", err,
 		))
 		return
 	}
+
+	body.Comment.ID = id
+	notifier.Comment(post, parent, body.Comment, mentioned)
+	events.Publish(live.New(live.CommentCreated, body.Comment, live.Post(postId)))
+
 	c.JSON(http.StatusCreated, struct {
 		Code   int    `json:"code"`
 		Status string `json:"status"`
@@ -72,22 +113,21 @@ func CreateComment(c *gin.Context, comments *mongo.Collection) {
 	}{
 		Code:   http.StatusCreated,
 		Status: "OK",
-		ID:     result.InsertedID.(primitive.ObjectID).String(),
+		ID:     id.String(),
 	})
 }
 
-func GetComment(c *gin.Context, comments *mongo.Collection) {
-	_, _, commentId, err := commentIdParams(c)
+func GetComment(c *gin.Context, comments storage.CommentStore, votes storage.VoteStore) {
+	_, postId, commentId, err := commentIdParams(c)
 	if err != nil {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
 	defer cancel()
 
-	var comment types.Comment
-	err = comments.FindOne(ctx, bson.M{"_id": commentId}).Decode(&comment)
-	if err == mongo.ErrNoDocuments {
+	comment, err := comments.Get(ctx, postId, commentId)
+	if err == storage.ErrNotFound {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrNotFound,
 			"comment not found",
@@ -103,11 +143,13 @@ func GetComment(c *gin.Context, comments *mongo.Collection) {
 		return
 	}
 
+	comment.MyVote = myVotes(c, votes, []primitive.ObjectID{comment.ID})[comment.ID]
+
 	log.Debug(msgs.DebugStruct, "comment", fmt.Sprintf("%#v\n", comment))
 	c.JSON(http.StatusOK, comment)
 }
 
-func GetComments(c *gin.Context, commentsColl *mongo.Collection) {
+func GetComments(c *gin.Context, commentsStore storage.CommentStore, votes storage.VoteStore) {
 	_, postId, err := postId(c)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
@@ -117,78 +159,83 @@ func GetComments(c *gin.Context, commentsColl *mongo.Collection) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
-
-	cursor, err := commentsColl.Find(ctx, bson.M{"post": postId})
+	p, err := pageParams(c, sortNew, sortTop, sortControversial)
 	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"bad options provided for find",
-			"reason", "bad options provided for GetComments",
-		))
 		return
 	}
 
-	var comments []types.Comment
-	err = cursor.All(ctx, &comments)
+	if c.Query("tree") == "true" {
+		commentTree(c, commentsStore, votes, postId, p)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	comments, err := commentsStore.List(ctx, postId, p.query())
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
-			"failed decoding cursor",
-			"GetComments cursor", err,
+			"failed listing comments",
+			"GetComments", err,
 		))
 		return
 	}
+
+	ids := make([]primitive.ObjectID, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	mine := myVotes(c, votes, ids)
+	for i := range comments {
+		comments[i].MyVote = mine[comments[i].ID]
+	}
+
 	log.Debug(msgs.DebugStruct, "users", fmt.Sprintf("%#v\n", comments))
-	c.JSON(http.StatusOK, comments)
+	respondPage(c, p, comments, commentKey(p))
 }
 
-func UpdateComment(c *gin.Context, boards, comments *mongo.Collection) {
-	boardId, _, commentId, err := commentIdParams(c)
+func UpdateComment(c *gin.Context, boards storage.BoardStore, posts storage.PostStore, comments storage.CommentStore, users storage.UserStore, revisions storage.RevisionStore, notifier *notify.Notifier) {
+	boardId, postId, commentId, err := commentIdParams(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
 	if err != nil {
 		return
 	}
 
 	var bdy struct {
 		Comment types.Comment `json:"comment"`
-		Requester types.Credentials `json:"requester"`
 	}
 	err = decodeBody(c, &bdy)
 	if err != nil {
 		return
 	}
 
-	if err := bdy.Requester.Authorize(); err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotAuthorized,
-			"user not authorized",
-			"error", err,
-		))
-		return
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
 
-	usr, err := bdy.Requester.ToUser()
+	board, err := boards.Get(ctx, boardId)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
-			"failed getting user",
+			"board making skill issue",
 		))
 		return
 	}
 
-	var board types.Board
-	err = getAndConvert(boards, boardId, &board)
+	_, err = posts.Get(ctx, boardId, postId)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"board making skill issue",
+			msgs.ErrNotFound,
+			"post not found!",
 		))
 		return
 	}
 
-	var comment types.Comment
-	err = getAndConvert(comments, commentId, &comment)
+	comment, err := comments.Get(ctx, postId, commentId)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
@@ -206,29 +253,37 @@ func UpdateComment(c *gin.Context, boards, comments *mongo.Collection) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
-
-	update := bson.M{"$set": bdy.Comment}
+	bdy.Comment.Parent = comment.Parent
+	bdy.Comment.Author, bdy.Comment.Post = comment.Author, comment.Post
+	bdy.Comment.Deletion = types.Deletion{}
 
-	updateResult, err := comments.UpdateByID(ctx, commentId, update)
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrBadOptions,
-			"options failure",
-			"UpdateComment", err,
-		))
+	rendered, _, ok := renderMarkdown(ctx, c, users, boards, bdy.Comment.Body)
+	if !ok {
+		return
+	}
+	bdy.Comment.BodyHTML = rendered
+	if !reviseComment(ctx, c, revisions, usr.ID, comment, &bdy.Comment) {
 		return
 	}
 
-	if updateResult.ModifiedCount == 0 {
+	err = comments.Update(ctx, commentId, bdy.Comment)
+	if err == storage.ErrNotFound || err == storage.ErrNotModified {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrUpdateFailed,
 			"failed to update the comment",
-			"UpdateComment", updateResult,
+			"UpdateComment", err,
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrBadOptions,
+			"options failure",
+			"UpdateComment", err,
 		))
 		return
 	}
+	notifier.Moderated(usr.ID, comment.Author, boardId, postId, &commentId, types.ActionEdited)
+
 	c.JSON(http.StatusAccepted, struct {
 		Code   int    `json:"code"`
 		Status string `json:"status"`
@@ -238,39 +293,112 @@ func UpdateComment(c *gin.Context, boards, comments *mongo.Collection) {
 	})
 }
 
-func DeleteComment(c *gin.Context, boards, comments *mongo.Collection) {
-	boardId, _, commentId, err := commentIdParams(c)
+// PatchComment changes the body of the comment in the merge patch body and
+// answers with the comment.
+func PatchComment(c *gin.Context, boards storage.BoardStore, posts storage.PostStore, comments storage.CommentStore, users storage.UserStore, revisions storage.RevisionStore, notifier *notify.Notifier) {
+	boardId, postId, commentId, err := commentIdParams(c)
 	if err != nil {
 		return
 	}
 
-	var bdy struct {
-		Requester types.Credentials `json:"requester"`
+	usr, err := requester(c)
+	if err != nil {
+		return
 	}
 
-	err = decodeBody(c, &bdy)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	board, err := boards.Get(ctx, boardId)
 	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"board not found",
+		))
 		return
 	}
 
-	if err := bdy.Requester.Authorize(); err != nil {
+	_, err = posts.Get(ctx, boardId, postId)
+	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotAuthorized,
-			"user not authorized",
-			"error", err,
+			msgs.ErrNotFound,
+			"post not found!",
 		))
+		return
 	}
 
-	usr, err := bdy.Requester.ToUser()
+	comment, err := comments.Get(ctx, postId, commentId)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"failed getting user",
+			msgs.ErrNotFound,
+			"comment not found",
+		))
+		return
+	}
+
+	if !(types.IsAdmin(usr) || types.IsModerator(board, usr) || comment.Author == usr.ID) {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrForbidden,
+			"action is forbidden!",
+			"PatchComment", "is neither an admin, moderator nor owner",
 		))
+		return
+	}
+
+	patched := comment
+	touched, ok := mergePatch(c, patchable{"body": &patched.Body})
+	if !ok {
+		return
+	}
+	if len(touched) == 0 {
+		c.JSON(http.StatusOK, comment)
+		return
 	}
 
-	var board types.Board
-	err = getAndConvert(boards, boardId, &board)
+	rendered, _, ok := renderMarkdown(ctx, c, users, boards, patched.Body)
+	if !ok {
+		return
+	}
+	patched.BodyHTML = rendered
+	if !reviseComment(ctx, c, revisions, usr.ID, comment, &patched) {
+		return
+	}
+
+	err = comments.Update(ctx, commentId, patched)
+	if err == storage.ErrNotModified {
+		c.JSON(http.StatusOK, comment)
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrBadOptions,
+			"options failure",
+			"PatchComment", err,
+		))
+		return
+	}
+	notifier.Moderated(usr.ID, comment.Author, boardId, postId, &commentId, types.ActionEdited)
+	if stored, err := comments.Get(ctx, postId, commentId); err == nil {
+		patched = stored
+	}
+
+	c.JSON(http.StatusOK, patched)
+}
+
+func DeleteComment(c *gin.Context, boards storage.BoardStore, posts storage.PostStore, comments storage.CommentStore, notifier *notify.Notifier, events live.Publisher) {
+	boardId, postId, commentId, err := commentIdParams(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Write)
+	defer cancel()
+
+	board, err := boards.Get(ctx, boardId)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
@@ -279,8 +407,16 @@ func DeleteComment(c *gin.Context, boards, comments *mongo.Collection) {
 		return
 	}
 
-	var comment types.Comment
-	err = getAndConvert(comments, commentId, &comment)
+	_, err = posts.Get(ctx, boardId, postId)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"post not found!",
+		))
+		return
+	}
+
+	comment, err := comments.Get(ctx, postId, commentId)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
@@ -298,27 +434,24 @@ func DeleteComment(c *gin.Context, boards, comments *mongo.Collection) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
-
-	deleteResult, err := comments.DeleteOne(ctx, bson.M{"_id": commentId})
-	if err != nil {
+	err = comments.SoftDelete(ctx, postId, commentId, usr.ID)
+	if err == storage.ErrNotFound {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrBadOptions,
-			"options failure",
+			msgs.ErrDeleteFailed,
+			"failed to delete the comment",
 			"DeleteComment", err,
 		))
 		return
-	}
-
-	if deleteResult.DeletedCount != 1 {
+	} else if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrDeleteFailed,
-			"failed to update the comment",
-			"DeleteComment", deleteResult,
+			msgs.ErrInternal,
+			"failed deleting the comment",
+			"DeleteComment", err,
 		))
 		return
 	}
+	notifier.Moderated(usr.ID, comment.Author, boardId, postId, &commentId, types.ActionDeleted)
+	events.Publish(live.New(live.CommentDeleted, liveTarget{ID: commentId, Board: boardId, Post: &postId}, live.Post(postId)))
 
 	c.JSON(http.StatusOK, struct {
 		Code   int    `json:"code"`
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"redoot/internal/msgs"
+	"redoot/internal/storage"
+	"redoot/internal/types"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultTreeDepth = 3
+	maxTreeDepth     = 10
+)
+
+// moreLink points back at the tree endpoint with the parent set, so the
+// client can continue loading replies where the response stopped.
+func moreLink(c *gin.Context, parent primitive.ObjectID, cursor string) string {
+	next := *c.Request.URL
+	query := next.Query()
+	query.Set("tree", "true")
+	query.Set("parent", parent.Hex())
+	query.Del("after")
+	query.Del("cursor")
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	next.RawQuery = query.Encode()
+	return next.RequestURI()
+}
+
+// pruneDeleted drops deleted comments without anything below them and masks
+// the rest as placeholders so their replies keep their place in the thread.
+func pruneDeleted(nodes []types.CommentNode) []types.CommentNode {
+	kept := []types.CommentNode{}
+	for _, node := range nodes {
+		node.Replies = pruneDeleted(node.Replies)
+		if node.DeletedAt != nil {
+			if len(node.Replies) == 0 && node.More == "" {
+				continue
+			}
+			node.Body = storage.DeletedPlaceholder
+			node.BodyHTML = storage.DeletedPlaceholderHTML
+			node.Author = primitive.NilObjectID
+			node.DeletedBy = nil
+		}
+		kept = append(kept, node)
+	}
+	return kept
+}
+
+func walkNodes(nodes []types.CommentNode, fn func(*types.CommentNode)) {
+	for i := range nodes {
+		fn(&nodes[i])
+		walkNodes(nodes[i].Replies, fn)
+	}
+}
+
+// commentTree answers with the comments of the post nested by parent, the
+// limit and sort apply to every level separately. Deleted comments are
+// fetched too and only pruned at the end so they don't cut their replies off.
+func commentTree(c *gin.Context, comments storage.CommentStore, votes storage.VoteStore, postId primitive.ObjectID, p page) {
+	depth := defaultTreeDepth
+	if d := c.Query("depth"); d != "" {
+		parsed, err := strconv.Atoi(d)
+		if err != nil || parsed < 1 || parsed > maxTreeDepth {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrWrongFormat,
+				"depth must be a number between 1 and 10",
+			))
+			return
+		}
+		depth = parsed
+	}
+
+	var parentId *primitive.ObjectID
+	if parent := c.Query("parent"); parent != "" {
+		id, err := primitive.ObjectIDFromHex(parent)
+		if err != nil {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrObjectIDConv,
+				"wrong parent id",
+				"message", err,
+			))
+			return
+		}
+		parentId = &id
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	roots, err := comments.Thread(ctx, postId, parentId, p.query())
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed getting comments",
+			"commentTree", err,
+		))
+		return
+	}
+
+	nodes := make([]types.CommentNode, len(roots))
+	level := []*types.CommentNode{}
+	for i, root := range roots {
+		nodes[i] = types.CommentNode{Comment: root, Replies: []types.CommentNode{}}
+		if int64(i) < p.limit {
+			level = append(level, &nodes[i])
+		}
+	}
+
+	key := commentKey(p)
+	for d := 1; d <= depth && len(level) > 0; d++ {
+		ids := make([]primitive.ObjectID, len(level))
+		for i, node := range level {
+			ids[i] = node.ID
+		}
+
+		if d == depth {
+			// only find out which of the deepest comments have replies
+			hasReplies, err := comments.Answered(ctx, ids)
+			if err != nil {
+				c.AbortWithStatusJSON(msgs.ReportError(
+					msgs.ErrInternal,
+					"failed finding replies",
+					"commentTree", err,
+				))
+				return
+			}
+			for _, node := range level {
+				if hasReplies[node.ID] {
+					node.More = moreLink(c, node.ID, "")
+				}
+			}
+			break
+		}
+
+		replies, err := comments.Replies(ctx, ids, p.query())
+		if err != nil {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrInternal,
+				"failed finding replies",
+				"commentTree", err,
+			))
+			return
+		}
+
+		byParent := map[primitive.ObjectID][]types.CommentNode{}
+		for _, reply := range replies {
+			byParent[*reply.Parent] = append(byParent[*reply.Parent], types.CommentNode{
+				Comment: reply,
+				Replies: []types.CommentNode{},
+			})
+		}
+
+		next := []*types.CommentNode{}
+		for _, node := range level {
+			children := byParent[node.ID]
+			if int64(len(children)) > p.limit {
+				children = children[:p.limit]
+				value, id := key(children[len(children)-1].Comment)
+				if p.field() == "" {
+					value = 0
+				}
+				node.More = moreLink(c, node.ID, encodeCursor(storage.Cursor{Value: value, ID: id}))
+			}
+			if children != nil {
+				node.Replies = children
+			}
+			for i := range node.Replies {
+				next = append(next, &node.Replies[i])
+			}
+		}
+		level = next
+	}
+
+	ids := []primitive.ObjectID{}
+	walkNodes(nodes, func(node *types.CommentNode) {
+		ids = append(ids, node.ID)
+	})
+	mine := myVotes(c, votes, ids)
+	walkNodes(nodes, func(node *types.CommentNode) {
+		node.MyVote = mine[node.ID]
+	})
+
+	resp := pageResponse{}
+	if int64(len(nodes)) > p.limit {
+		nodes = nodes[:p.limit]
+		value, id := key(nodes[len(nodes)-1].Comment)
+		resp = nextPage(c, p, value, id)
+	}
+	resp.Data = pruneDeleted(nodes)
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"redoot/internal/content"
+	"redoot/internal/media"
+	"redoot/internal/msgs"
+	"redoot/internal/storage"
+	"redoot/internal/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxUploadBody leaves room for the multipart framing around the image.
+const maxUploadBody = content.MaxImageBytes + 64<<10
+
+// mediaCache lets clients keep media forever, the id changes with the bytes.
+const mediaCache = "public, max-age=31536000, immutable"
+
+// UploadMedia stores the image in the file field of a multipart form.
+func UploadMedia(c *gin.Context, library *media.Library) {
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBody)
+	header, err := c.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || (err == nil && header.Size > content.MaxImageBytes) {
+		reportMediaError(c, content.ErrImageSize)
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrWrongFormat,
+			"expected a multipart form with the image in the file field",
+			"UploadMedia", err,
+		))
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed reading the upload",
+			"UploadMedia", err,
+		))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, content.MaxImageBytes+1))
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed reading the upload",
+			"UploadMedia", err,
+		))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Write)
+	defer cancel()
+
+	saved, err := library.Save(ctx, data, usr.ID)
+	if err != nil {
+		reportMediaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, struct {
+		Code   int         `json:"code"`
+		Status string      `json:"status"`
+		ID     string      `json:"id"`
+		Media  types.Media `json:"media"`
+	}{
+		Code:   http.StatusCreated,
+		Status: "OK",
+		ID:     saved.ID,
+		Media:  saved,
+	})
+}
+
+// GetMedia serves the media, or its thumbnail with size=thumb.
+func GetMedia(c *gin.Context, library *media.Library) {
+	id := c.Param("id")
+	if !media.IsID(id) {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrWrongFormat,
+			"media id must be a hex encoded sha256",
+			"id", id,
+		))
+		return
+	}
+
+	var thumb bool
+	switch c.Query("size") {
+	case "", "full":
+	case "thumb":
+		thumb = true
+	default:
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrWrongFormat,
+			"size must be full or thumb",
+			"size", c.Query("size"),
+		))
+		return
+	}
+
+	etag := `"` + id + `"`
+	if thumb {
+		etag = `"` + media.ThumbID(id) + `"`
+	}
+
+	// media never changes, a client holding the tag has the current bytes
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", mediaCache)
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Write)
+	defer cancel()
+
+	found, data, err := library.Open(ctx, id, thumb)
+	if err == storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"media not found",
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed reading the media",
+			"GetMedia", err,
+		))
+		return
+	}
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", mediaCache)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, found.ContentType, data)
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveImage turns an avatar or the body of an image post into stored
+// media, an inline image is saved on the way.
+func resolveImage(c *gin.Context, library *media.Library, ref string, uploader primitive.ObjectID) (types.Media, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Write)
+	defer cancel()
+
+	found, err := library.Resolve(ctx, ref, uploader)
+	if err != nil {
+		reportMediaError(c, err)
+		return types.Media{}, false
+	}
+	return found, true
+}
+
+func reportMediaError(c *gin.Context, err error) {
+	switch err {
+	case content.ErrImageSize:
+		c.AbortWithStatusJSON(msgs.ReportError(msgs.ErrTooLarge, err.Error()))
+	case media.ErrType:
+		c.AbortWithStatusJSON(msgs.ReportError(msgs.ErrMediaType, err.Error()))
+	case content.ErrImage, content.ErrImageDimensions, media.ErrUnknown:
+		c.AbortWithStatusJSON(msgs.ReportError(msgs.ErrWrongFormat, err.Error()))
+	default:
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed storing the media",
+			"media", err,
+		))
+	}
+}
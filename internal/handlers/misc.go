@@ -3,24 +3,55 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"redoot/internal/markdown"
 	"redoot/internal/msgs"
+	"redoot/internal/storage"
 	"redoot/internal/types"
+	"regexp"
+	"slices"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// Timeouts bound how long handlers wait on the storage. Query covers single
+// reads and writes, Write the cascading ones like deletes, restores and
+// backups.
+type Timeouts struct {
+	Query    time.Duration
+	Write    time.Duration
+	Reap     time.Duration
+	Shutdown time.Duration
+}
+
+var timeouts = Timeouts{
+	Query:    time.Millisecond * 200,
+	Write:    time.Second,
+	Reap:     time.Second * 5,
+	Shutdown: time.Second * 5,
+}
+
+var bcryptCost = bcrypt.MinCost
+
+func SetTimeouts(t Timeouts) {
+	timeouts = t
+}
+
+func SetBcryptCost(cost int) {
+	bcryptCost = cost
+}
+
 type findResultUsers struct {
 	users []types.User
 	err   error
@@ -31,11 +62,6 @@ type findResultBoards struct {
 	err    error
 }
 
-type findResultPosts struct {
-	posts []types.Post
-	err   error
-}
-
 func decodeBody(c *gin.Context, bdy interface{}) error {
 	log.Debug(msgs.DebugStruct, "bdy", bdy)
 	err := json.NewDecoder(c.Request.Body).Decode(bdy)
@@ -50,22 +76,74 @@ func decodeBody(c *gin.Context, bdy interface{}) error {
 	return nil
 }
 
-func Interrupt(s *http.Server, collections ...*mongo.Collection) {
+func requester(c *gin.Context) (types.User, error) {
+	usr, ok := c.Get(requesterKey)
+	if !ok {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotAuthorized,
+			"missing or invalid bearer token",
+		))
+		return types.User{}, msgs.ErrNotAuthorized
+	}
+	return usr.(types.User), nil
+}
+
+// Fields the field searches accept, anything else could probe passwords or
+// emails through the regex.
+var (
+	userSearchFields  = []string{"name", "bio", "pronouns"}
+	boardSearchFields = []string{"name", "bio", "rules"}
+)
+
+// searchParams checks the query only names searchable fields and escapes the
+// values so they match literally.
+func searchParams(c *gin.Context, fields []string) (url.Values, error) {
+	query := url.Values{}
+	for field, values := range c.Request.URL.Query() {
+		if !slices.Contains(fields, field) {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrWrongFormat,
+				"field can't be searched",
+				"field", field,
+			))
+			return nil, msgs.ErrWrongFormat
+		}
+		for _, v := range values {
+			query.Add(field, regexp.QuoteMeta(v))
+		}
+	}
+	return query, nil
+}
+
+// unique drops the items with an id already seen, keeping the first.
+func unique[T any](items []T, id func(T) primitive.ObjectID) []T {
+	seen := map[primitive.ObjectID]bool{}
+	result := []T{}
+	for _, item := range items {
+		if !seen[id(item)] {
+			seen[id(item)] = true
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func nameAvailable(ctx context.Context, users storage.UserStore, name string) bool {
+	_, err := users.ByName(ctx, name)
+	return err == storage.ErrNotFound
+}
+
+// Interrupt waits for SIGINT or SIGTERM and lets in-flight requests finish,
+// the data is left untouched.
+func Interrupt(s *http.Server) {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Info("Shutting down the Server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Shutdown)
 	defer cancel()
 
-	for _, c := range collections {
-		err := c.Drop(ctx)
-		if err != nil {
-			log.Fatal("Failed to drop collection ", "reason:", err)
-		}
-	}
-
 	if err := s.Shutdown(ctx); err != nil {
 		log.Fatal("Error Shutting down: ", "reason:", err)
 	}
@@ -196,166 +274,55 @@ func idFromParams(c *gin.Context) (primitive.ObjectID, error) {
 	return objid, nil
 }
 
-func findByFieldUsers(ctx context.Context, coll *mongo.Collection, key, value string, ch chan<- findResultUsers, wg *sync.WaitGroup) {
+func findByFieldUsers(ctx context.Context, users storage.UserStore, key, value string, ch chan<- findResultUsers, wg *sync.WaitGroup) {
 	log.Debug("Search started for", key, value)
 	resp := findResultUsers{}
 
-	pipeline := mongo.Pipeline{
-		bson.D{
-			{Key: "$match", Value: bson.D{
-				{Key: key, Value: primitive.Regex{Pattern: value, Options: "i"}},
-			}},
-		},
-	}
-
-	cursor, err := coll.Aggregate(ctx, pipeline)
+	found, err := users.Search(ctx, key, value)
 	if err != nil {
-		log.Debug("Error occurred in Find", key, value)
+		log.Debug("Error occurred in Search", key, value)
 		resp.users = nil
 		resp.err = err
 		ch <- resp
 		wg.Done()
 		return
 	}
-
-	var users []types.User
-	err = cursor.All(ctx, &users)
-	if err != nil {
-		log.Debug("Error occured in cursor.All", key, value)
-		resp.users = nil
-		resp.err = err
-		ch <- resp
-		wg.Done()
-		return
-	}
-	resp.users = users
+	resp.users = found
 	resp.err = err
 	ch <- resp
 	wg.Done()
 	log.Debug("No errors for", key, value)
 }
 
-func findByFieldBoards(ctx context.Context, coll *mongo.Collection, key, value string, ch chan<- findResultBoards, wg *sync.WaitGroup) {
+func findByFieldBoards(ctx context.Context, boards storage.BoardStore, key, value string, ch chan<- findResultBoards, wg *sync.WaitGroup) {
 	log.Debug("Search started for", key, value)
 	resp := findResultBoards{}
 
-	pipeline := mongo.Pipeline{
-		bson.D{
-			{Key: "$match", Value: bson.D{
-				{Key: key, Value: primitive.Regex{Pattern: value, Options: "i"}},
-			}},
-		},
-	}
-
-	cursor, err := coll.Aggregate(ctx, pipeline)
-	if err != nil {
-		log.Debug("Error occurred in Find", key, value)
-		resp.boards = nil
-		resp.err = err
-		ch <- resp
-		wg.Done()
-		return
-	}
-
-	var boards []types.Board
-	err = cursor.All(ctx, &boards)
+	found, err := boards.Search(ctx, key, value)
 	if err != nil {
-		log.Debug("Error occured in cursor.All", key, value)
+		log.Debug("Error occurred in Search", key, value)
 		resp.boards = nil
 		resp.err = err
 		ch <- resp
 		wg.Done()
 		return
 	}
-	resp.boards = boards
+	resp.boards = found
 	resp.err = err
 	ch <- resp
 	wg.Done()
 	log.Debug("No errors for", key, value)
 }
 
-func getAndConvert(c *mongo.Collection, id primitive.ObjectID, result any) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+// ExportToFile dumps everything for an admin. Password hashes are only
+// included with credentials=true, a dump without them restores users that
+// can't log in.
+func ExportToFile(c *gin.Context, backup storage.Backup) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Write)
 	defer cancel()
 
-	err := c.FindOne(ctx, bson.M{"_id": id}).Decode(result)
-	if err == mongo.ErrNoDocuments {
-		return err
-	} else if err != nil {
-		return errors.New("sister, that's skill issue right there")
-	}
-
-	return nil
-}
-
-func findByFieldPosts(ctx context.Context, coll *mongo.Collection, key, value string, ch chan<- findResultPosts, wg *sync.WaitGroup) {
-	log.Debug("Search started for", key, value)
-	resp := findResultPosts{}
-
-	pipeline := mongo.Pipeline{
-		bson.D{
-			{Key: "$match", Value: bson.D{
-				{Key: key, Value: primitive.Regex{Pattern: value, Options: "i"}},
-			}},
-		},
-	}
-
-	cursor, err := coll.Aggregate(ctx, pipeline)
-	if err != nil {
-		log.Debug("Error occurred in Find", key, value)
-		resp.posts = nil
-		resp.err = err
-		ch <- resp
-		wg.Done()
-		return
-	}
-
-	var posts []types.Post
-	err = cursor.All(ctx, &posts)
+	usr, err := requester(c)
 	if err != nil {
-		log.Debug("Error occurred in cursor.All", key, value)
-		resp.posts = nil
-		resp.err = err
-		ch <- resp
-		wg.Done()
-		return
-	}
-	resp.posts = posts
-	resp.err = err
-	ch <- resp
-	wg.Done()
-	log.Debug("No errors for", key, value)
-}
-
-func ExportToFile(c *gin.Context, users, boards, posts, comments *mongo.Collection) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
-
-	var creds types.Credentials
-	err := decodeBody(c, &creds)
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrWrongFormat,
-			"malformed body",
-		))
-		return
-	}
-
-	err = creds.Authorize()
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotAuthorized,
-			"user not authorized",
-		))
-		return
-	}
-
-	usr, err := creds.ToUser()
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"internal error has occurred",
-		))
 		return
 	}
 
@@ -367,98 +334,46 @@ func ExportToFile(c *gin.Context, users, boards, posts, comments *mongo.Collecti
 		return
 	}
 
-	result := struct {
-		Users    []types.User    `json:"users"`
-		Boards   []types.Board   `json:"boards"`
-		Posts    []types.Post    `json:"posts"`
-		Comments []types.Comment `json:"comments"`
-	}{}
-
-	cursor, err := users.Find(ctx, bson.M{})
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"failed getting users",
-		))
-		return
-	}
-
-	err = cursor.All(ctx, &result.Users)
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"failed decoding users",
-		))
-		return
-	}
-
-	cursor, err = boards.Find(ctx, bson.M{})
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"failed getting boards",
-		))
-		return
-	}
-
-	err = cursor.All(ctx, &result.Boards)
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"failed decoding boards",
-		))
-		return
+	var credentials bool
+	if raw := c.Query("credentials"); raw != "" {
+		credentials, err = strconv.ParseBool(raw)
+		if err != nil {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrWrongFormat,
+				"credentials must be true or false",
+				"credentials", raw,
+			))
+			return
+		}
 	}
 
-	cursor, err = posts.Find(ctx, bson.M{})
+	result, err := backup.Export(ctx)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
-			"failed getting posts",
+			"failed exporting",
+			"ExportToFile", err,
 		))
 		return
 	}
 
-	err = cursor.All(ctx, &result.Posts)
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"failed decoding posts",
-		))
-		return
+	if !credentials {
+		for i := range result.Users {
+			result.Users[i].Password = ""
+		}
 	}
 
-	cursor, err = comments.Find(ctx, bson.M{})
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"failed getting comments",
-		))
-		return
-	}
+	c.JSON(http.StatusOK, result)
+}
 
-	err = cursor.All(ctx, &result.Comments)
+func ImportFromFile(c *gin.Context, backup storage.Backup) {
+	usr, err := requester(c)
 	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"failed decoding comments",
-		))
 		return
 	}
 
-	c.JSON(http.StatusOK, result)
-}
-
-func ImportFromFile(c *gin.Context, users, boards, posts, comments *mongo.Collection) {
-	body := struct {
-		Requester types.Credentials `json:"requester"`
-		Users     []types.User      `json:"users"`
-		Boards    []types.Board     `json:"boards"`
-		Posts     []types.Post      `json:"posts"`
-		Comments  []types.Comment   `json:"comments"`
-	}{}
-
-	err := decodeBody(c, &body)
+	var body storage.Dump
+	err = decodeBody(c, &body)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
@@ -469,147 +384,73 @@ func ImportFromFile(c *gin.Context, users, boards, posts, comments *mongo.Collec
 
 	log.Debug(msgs.DebugStruct, "body", body)
 
-	err = body.Requester.Authorize()
-	if err != nil {
+	if !types.IsAdmin(usr) {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrNotAuthorized,
-			"user not authorized",
+			"only admins have access to this feature",
 		))
-		log.Debug(msgs.DebugStruct, "requester", body.Requester)
 		return
 	}
 
-	usr, err := body.Requester.ToUser()
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Write)
+	defer cancel()
+
+	err = backup.Import(ctx, body)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
-			"failed to get the user",
-		))
-		return
-	}
-
-	if !types.IsAdmin(usr) {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotAuthorized,
-			"only admins have access to this feature",
+			err.Error(),
 		))
 		return
 	}
 
-	var wg sync.WaitGroup
-	errs := make(chan error, 4)
-
-	wg.Add(4)
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-		defer cancel()
-
-		if len(body.Users) == 0 {
-			wg.Done()
-			return
-		}
-		inters := make([]interface{}, len(body.Users))
-		for i, p := range body.Users {
-			log.Debug("name", "action", "adding")
-			inters[i] = p
-		}
-		log.Debug("name", "action", "insert many")
-		_, err := users.InsertMany(ctx, inters)
-		if err != nil {
-			log.Debug("name", "action", "waiting")
-			errs <- err
-		}
-		wg.Done()
-		log.Debug("name", "action", "done")
-	}()
-
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-		defer cancel()
-
-		if len(body.Boards) == 0 {
-			wg.Done()
-			return
-		}
-		inters := make([]interface{}, len(body.Boards))
-		for i, p := range body.Boards {
-			log.Debug("name", "action", "adding")
-			inters[i] = p
-		}
-		log.Debug("name", "action", "insert many")
-		_, err := boards.InsertMany(ctx, inters)
-		if err != nil {
-			log.Debug("name", "action", "waiting")
-			errs <- err
-		}
-		wg.Done()
-		log.Debug("name", "action", "done")
-	}()
-
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-		defer cancel()
+	c.JSON(http.StatusCreated, struct {
+		Code    int    `json:"code"`
+		Content string `json:"content"`
+	}{
+		Code:    http.StatusCreated,
+		Content: "added",
+	})
+}
 
-		if len(body.Posts) == 0 {
-			wg.Done()
-			return
-		}
-		inters := make([]interface{}, len(body.Posts))
-		for i, p := range body.Posts {
-			log.Debug("posts", "action", "adding")
-			inters[i] = p
-		}
-		log.Debug("posts", "action", "insert many")
-		_, err := posts.InsertMany(ctx, inters)
-		if err != nil {
-			log.Debug("posts", "action", "waiting")
-			errs <- err
+// renderMarkdown renders a body with the users and boards it mentions
+// linked, mentions of ones that don't exist stay text. It also returns the
+// mentioned users.
+func renderMarkdown(ctx context.Context, c *gin.Context, users storage.UserStore, boards storage.BoardStore, src string) (string, []primitive.ObjectID, bool) {
+	links := markdown.Links{Users: map[string]string{}, Boards: map[string]string{}}
+	userNames, boardNames := markdown.Mentions(src)
+	mentioned := []primitive.ObjectID{}
+
+	for _, name := range userNames {
+		usr, err := users.ByName(ctx, name)
+		if err == storage.ErrNotFound {
+			continue
+		} else if err != nil {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrInternal,
+				"failed resolving mentions",
+				"renderMarkdown", err,
+			))
+			return "", nil, false
 		}
-		wg.Done()
-		log.Debug("posts", "action", "done")
-	}()
-
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-		defer cancel()
+		links.Users[name] = "/users/" + usr.ID.Hex()
+		mentioned = append(mentioned, usr.ID)
+	}
 
-		if len(body.Comments) == 0 {
-			wg.Done()
-			return
-		}
-		inters := make([]interface{}, len(body.Comments))
-		for i, p := range body.Comments {
-			log.Debug("comments", "action", "adding")
-			inters[i] = p
-		}
-		log.Debug("comments", "action", "insert many")
-		_, err := comments.InsertMany(ctx, inters)
+	for _, name := range boardNames {
+		named, err := boards.Search(ctx, "name", "^"+regexp.QuoteMeta(name)+"$")
 		if err != nil {
-			log.Debug("comments", "action", "waiting")
-			errs <- err
-		}
-		wg.Done()
-		log.Debug("comments", "action", "done")
-	}()
-	log.Debug("waiting")
-	wg.Wait()
-	close(errs)
-
-	if len(errs) > 0 {
-		for e := range errs {
 			c.AbortWithStatusJSON(msgs.ReportError(
 				msgs.ErrInternal,
-				e.Error(),
+				"failed resolving mentions",
+				"renderMarkdown", err,
 			))
+			return "", nil, false
+		}
+		if len(named) > 0 {
+			links.Boards[name] = "/boards/" + named[0].ID.Hex()
 		}
-		return
 	}
 
-	c.JSON(http.StatusCreated, struct {
-		Code    int    `json:"code"`
-		Content string `json:"content"`
-	}{
-		Code:    http.StatusCreated,
-		Content: "added",
-	})
+	return markdown.Render(src, links), mentioned, true
 }
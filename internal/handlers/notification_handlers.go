@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"redoot/internal/msgs"
+	"redoot/internal/storage"
+	"redoot/internal/types"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type unreadCounts struct {
+	Total int                            `json:"total"`
+	Types map[types.NotificationType]int `json:"types"`
+}
+
+// GetNotifications lists the inbox of the requester newest first together
+// with how much of it is unread, unread=true and type narrow the listing.
+func GetNotifications(c *gin.Context, notifications storage.NotificationStore) {
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	p, err := pageParams(c, sortNew)
+	if err != nil {
+		return
+	}
+
+	var unread bool
+	if raw := c.Query("unread"); raw != "" {
+		unread, err = strconv.ParseBool(raw)
+		if err != nil {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrWrongFormat,
+				"unread must be true or false",
+				"unread", raw,
+			))
+			return
+		}
+	}
+
+	kind := types.NotificationType(c.Query("type"))
+	if kind != "" && !kind.Valid() {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrWrongFormat,
+			"unknown notification type",
+			"type", kind,
+		))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	inbox, err := notifications.List(ctx, usr.ID, unread, kind, p.query())
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed listing notifications",
+			"GetNotifications", err,
+		))
+		return
+	}
+
+	counts, err := notifications.Unread(ctx, usr.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed counting unread notifications",
+			"GetNotifications", err,
+		))
+		return
+	}
+
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	c.JSON(http.StatusOK, struct {
+		pageResponse
+		Unread unreadCounts `json:"unread"`
+	}{
+		pageResponse: pageOf(c, p, inbox, func(n types.Notification) (float64, primitive.ObjectID) {
+			return 0, n.ID
+		}),
+		Unread: unreadCounts{Total: total, Types: counts},
+	})
+}
+
+func ReadNotification(c *gin.Context, notifications storage.NotificationStore) {
+	objid, err := idFromParams(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	err = notifications.MarkRead(ctx, usr.ID, objid)
+	if err == storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"notification not found",
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed marking the notification read",
+			"ReadNotification", err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, struct {
+		Code   int    `json:"code"`
+		Status string `json:"status"`
+	}{
+		Code:   http.StatusOK,
+		Status: "OK",
+	})
+}
+
+func ReadAllNotifications(c *gin.Context, notifications storage.NotificationStore) {
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Write)
+	defer cancel()
+
+	marked, err := notifications.MarkAllRead(ctx, usr.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed marking notifications read",
+			"ReadAllNotifications", err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, struct {
+		Code   int    `json:"code"`
+		Status string `json:"status"`
+		Marked int    `json:"marked"`
+	}{
+		Code:   http.StatusOK,
+		Status: "OK",
+		Marked: marked,
+	})
+}
+
+func GetNotificationPreferences(c *gin.Context) {
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, struct {
+		Preferences map[types.NotificationType]bool `json:"preferences"`
+	}{
+		Preferences: usr.Muted.Preferences(),
+	})
+}
+
+// UpdateNotificationPreferences turns notification types on or off, types
+// left out of the body keep their setting.
+func UpdateNotificationPreferences(c *gin.Context, users storage.UserStore) {
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	var body struct {
+		Preferences map[types.NotificationType]bool `json:"preferences"`
+	}
+	err = decodeBody(c, &body)
+	if err != nil {
+		return
+	}
+
+	for kind := range body.Preferences {
+		if !kind.Valid() {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrWrongFormat,
+				"unknown notification type",
+				"type", kind,
+			))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	current, err := users.Get(ctx, usr.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"user not found",
+			"UpdateNotificationPreferences", err,
+		))
+		return
+	}
+
+	for kind, enabled := range body.Preferences {
+		current.Muted.Set(kind, enabled)
+	}
+
+	err = users.Update(ctx, usr.ID, current)
+	if err != nil && err != storage.ErrNotModified {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrUpdateFailed,
+			"failed updating notification preferences",
+			"UpdateNotificationPreferences", err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, struct {
+		Preferences map[types.NotificationType]bool `json:"preferences"`
+	}{
+		Preferences: current.Muted.Preferences(),
+	})
+}
+
+// Subscribe notifies the requester of new posts in the board.
+func Subscribe(c *gin.Context, boards storage.BoardStore, subscriptions storage.SubscriptionStore) {
+	objid, err := idFromParams(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	_, err = boards.Get(ctx, objid)
+	if err == storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"board not found",
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"board finding skill issue",
+			"Subscribe", err,
+		))
+		return
+	}
+
+	err = subscriptions.Subscribe(ctx, usr.ID, objid)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed subscribing",
+			"Subscribe", err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusCreated, struct {
+		Code   int    `json:"code"`
+		Status string `json:"status"`
+	}{
+		Code:   http.StatusCreated,
+		Status: "OK",
+	})
+}
+
+// GetSubscriptions lists the boards the requester subscribed to, newest
+// subscription first.
+func GetSubscriptions(c *gin.Context, subscriptions storage.SubscriptionStore) {
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	subs, err := subscriptions.Of(ctx, usr.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed listing subscriptions",
+			"GetSubscriptions", err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, struct {
+		Data []types.Subscription `json:"data"`
+	}{
+		Data: subs,
+	})
+}
+
+func Unsubscribe(c *gin.Context, subscriptions storage.SubscriptionStore) {
+	objid, err := idFromParams(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	err = subscriptions.Unsubscribe(ctx, usr.ID, objid)
+	if err == storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"not subscribed to the board",
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed unsubscribing",
+			"Unsubscribe", err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, struct {
+		Code   int    `json:"code"`
+		Status string `json:"status"`
+	}{
+		Code:   http.StatusOK,
+		Status: "OK",
+	})
+}
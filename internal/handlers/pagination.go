@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"redoot/internal/msgs"
+	"redoot/internal/storage"
+	"redoot/internal/types"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultPageLimit = 25
+	maxPageLimit     = 100
+)
+
+const (
+	sortNew           = "new"
+	sortTop           = "top"
+	sortHot           = "hot"
+	sortRising        = "rising"
+	sortControversial = "controversial"
+	sortRelevance     = "relevance"
+)
+
+var sortFields = map[string]string{
+	sortNew:           "",
+	sortTop:           storage.SortVotes,
+	sortHot:           storage.SortHot,
+	sortRising:        storage.SortRising,
+	sortControversial: storage.SortControversy,
+	sortRelevance:     storage.SortScore,
+}
+
+// risingWindow is how far back rising looks, older posts would otherwise
+// keep the velocity of their last vote forever.
+const risingWindow = 24 * time.Hour
+
+// windows are the spans t narrows top and controversial post listings to.
+var windows = map[string]time.Duration{
+	"hour":  time.Hour,
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+	"all":   0,
+}
+
+type page struct {
+	limit int64
+	sort  string
+	after *storage.Cursor
+	since *time.Time
+}
+
+type pageResponse struct {
+	Data   any    `json:"data"`
+	Cursor string `json:"cursor,omitempty"`
+	Next   string `json:"next,omitempty"`
+}
+
+// pageParams reads limit, sort and cursor (or after) from the query, the
+// first of the allowed sorts is the default.
+func pageParams(c *gin.Context, sorts ...string) (page, error) {
+	p := page{
+		limit: defaultPageLimit,
+		sort:  sorts[0],
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		l, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || l < 1 || l > maxPageLimit {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrWrongFormat,
+				"limit must be a number between 1 and 100",
+			))
+			return page{}, msgs.ErrWrongFormat
+		}
+		p.limit = l
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		if !slices.Contains(sorts, sort) {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrWrongFormat,
+				"unsupported sort",
+				"sort", sort,
+			))
+			return page{}, msgs.ErrWrongFormat
+		}
+		p.sort = sort
+	}
+
+	token := c.Query("cursor")
+	if token == "" {
+		token = c.Query("after")
+	}
+	if token != "" {
+		after, err := decodeCursor(token)
+		if err != nil {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrWrongFormat,
+				"malformed cursor",
+				"cursor", token,
+			))
+			return page{}, msgs.ErrWrongFormat
+		}
+		p.after = &after
+	}
+
+	return p, nil
+}
+
+// postParams is pageParams for post listings, t narrows top and
+// controversial down to the posts of the last hour, day, week, month or year
+// and rising only ever looks at the last day.
+func postParams(c *gin.Context, sorts ...string) (page, error) {
+	p, err := pageParams(c, sorts...)
+	if err != nil {
+		return p, err
+	}
+
+	window := time.Duration(0)
+	if t := c.Query("t"); t != "" {
+		var ok bool
+		window, ok = windows[t]
+		if !ok {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrWrongFormat,
+				"t must be hour, day, week, month, year or all",
+				"t", t,
+			))
+			return page{}, msgs.ErrWrongFormat
+		}
+	}
+
+	switch p.sort {
+	case sortRising:
+		window = risingWindow
+	case sortNew, sortHot:
+		window = 0
+	}
+
+	if window > 0 {
+		since := time.Now().Add(-window)
+		p.since = &since
+	}
+	return p, nil
+}
+
+func decodeCursor(token string) (storage.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return storage.Cursor{}, err
+	}
+
+	var cursor storage.Cursor
+	err = json.Unmarshal(raw, &cursor)
+	return cursor, err
+}
+
+func encodeCursor(cursor storage.Cursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func (p page) field() string {
+	return sortFields[p.sort]
+}
+
+func (p page) query() storage.Page {
+	return storage.Page{
+		Limit: p.limit,
+		Sort:  p.field(),
+		After: p.after,
+		Since: p.since,
+	}
+}
+
+// respondPage trims the extra item returned by the stores and answers with
+// the page envelope, key returns the sort value and the id of an item.
+func respondPage[T any](c *gin.Context, p page, items []T, key func(T) (float64, primitive.ObjectID)) {
+	c.JSON(http.StatusOK, pageOf(c, p, items, key))
+}
+
+// pageOf builds the envelope respondPage answers with.
+func pageOf[T any](c *gin.Context, p page, items []T, key func(T) (float64, primitive.ObjectID)) pageResponse {
+	if items == nil {
+		items = []T{}
+	}
+
+	resp := pageResponse{}
+	if int64(len(items)) > p.limit {
+		items = items[:p.limit]
+		value, id := key(items[len(items)-1])
+		resp = nextPage(c, p, value, id)
+	}
+	resp.Data = items
+	return resp
+}
+
+// nextPage builds the envelope pointing past the item with the given sort
+// value and id.
+func nextPage(c *gin.Context, p page, value float64, id primitive.ObjectID) pageResponse {
+	if p.field() == "" {
+		value = 0
+	}
+	resp := pageResponse{Cursor: encodeCursor(storage.Cursor{Value: value, ID: id})}
+
+	next := *c.Request.URL
+	query := next.Query()
+	query.Del("after")
+	query.Set("cursor", resp.Cursor)
+	next.RawQuery = query.Encode()
+	resp.Next = next.RequestURI()
+	return resp
+}
+
+func postKey(p page) func(types.Post) (float64, primitive.ObjectID) {
+	return func(post types.Post) (float64, primitive.ObjectID) {
+		switch p.sort {
+		case sortControversial:
+			return post.Controversy, post.ID
+		case sortHot:
+			return post.Hot, post.ID
+		case sortRising:
+			return post.Rising, post.ID
+		}
+		return float64(post.Votes), post.ID
+	}
+}
+
+func nicePostKey(p page) func(types.NicePost) (float64, primitive.ObjectID) {
+	return func(post types.NicePost) (float64, primitive.ObjectID) {
+		switch p.sort {
+		case sortControversial:
+			return post.Controversy, post.ID
+		case sortHot:
+			return post.Hot, post.ID
+		case sortRising:
+			return post.Rising, post.ID
+		}
+		return float64(post.Votes), post.ID
+	}
+}
+
+func commentKey(p page) func(types.Comment) (float64, primitive.ObjectID) {
+	return func(comment types.Comment) (float64, primitive.ObjectID) {
+		if p.sort == sortControversial {
+			return comment.Controversy, comment.ID
+		}
+		return float64(comment.Votes), comment.ID
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"redoot/internal/msgs"
+	"reflect"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// patchable maps the JSON names of the fields a PATCH may change to the
+// fields themselves.
+type patchable map[string]any
+
+// mergePatch applies a JSON Merge Patch body onto the fields, null resets a
+// field and a field that isn't patchable rejects the whole patch. It returns
+// the names of the fields the patch touched.
+func mergePatch(c *gin.Context, fields patchable) ([]string, bool) {
+	var patch map[string]json.RawMessage
+	err := json.NewDecoder(c.Request.Body).Decode(&patch)
+	if err != nil || patch == nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrWrongFormat,
+			"the body must be a JSON object",
+		))
+		return nil, false
+	}
+
+	touched := []string{}
+	for name := range patch {
+		if _, ok := fields[name]; !ok {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrReadOnly,
+				name+" can't be changed",
+				"field", name,
+			))
+			return nil, false
+		}
+		touched = append(touched, name)
+	}
+	sort.Strings(touched)
+
+	for _, name := range touched {
+		field := fields[name]
+		if bytes.Equal(bytes.TrimSpace(patch[name]), []byte("null")) {
+			reflect.ValueOf(field).Elem().SetZero()
+			continue
+		}
+		if err := json.Unmarshal(patch[name], field); err != nil {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrWrongFormat,
+				"malformed "+name,
+				"field", name,
+			))
+			return nil, false
+		}
+	}
+	return touched, true
+}
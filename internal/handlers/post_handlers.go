@@ -3,123 +3,164 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"redoot/internal/content"
+	"redoot/internal/live"
+	"redoot/internal/media"
 	"redoot/internal/msgs"
+	"redoot/internal/notify"
+	"redoot/internal/storage"
 	"redoot/internal/types"
-	"sync"
-	"time"
 
-	"github.com/charmbracelet/log"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func MostPopular(c *gin.Context, posts *mongo.Collection) {
+func MostPopular(c *gin.Context, posts storage.PostStore) {
+	p, err := postParams(c, sortHot, sortRising, sortNew, sortTop, sortControversial)
+	if err != nil {
+		return
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
 	defer cancel()
 
-	lookupAuthorStage := bson.D{
-		{Key: "$lookup", Value: bson.D{
-			{Key: "from", Value: "users"},
-			{Key: "localField", Value: "author"},
-			{Key: "foreignField", Value: "_id"},
-			{Key: "as", Value: "authorInfo"},
-		}},
+	postssss, err := posts.Popular(ctx, p.query())
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"connection failed",
+			"MostPopular", err,
+		))
+		return
 	}
 
-	lookupBoardStage := bson.D{
-		{Key: "$lookup", Value: bson.D{
-			{Key: "from", Value: "boards"},
-			{Key: "localField", Value: "board"},
-			{Key: "foreignField", Value: "_id"},
-			{Key: "as", Value: "boardInfo"},
-		}},
-	}
+	respondPage(c, p, postssss, nicePostKey(p))
+}
 
-	projectFieldsStage := bson.D{
-		{Key: "$project", Value: bson.D{
-			{Key: "title", Value: 1},
-			{Key: "bodyType", Value: 1},
-			{Key: "bodyContent", Value: 1},
-			{Key: "votes", Value: 1},
-			{Key: "author", Value: bson.D{{Key: "$arrayElemAt", Value: bson.A{"$authorInfo.name", 0}}}},
-			{Key: "board", Value: bson.D{{Key: "$arrayElemAt", Value: bson.A{"$boardInfo.name", 0}}}},
-		}},
+// GetFeed lists the posts of the boards the requester subscribed to, hottest
+// first. Anonymous requesters and ones without subscriptions get the posts
+// of every board instead.
+func GetFeed(c *gin.Context, posts storage.PostStore, subscriptions storage.SubscriptionStore) {
+	p, err := postParams(c, sortHot, sortRising, sortNew, sortTop, sortControversial)
+	if err != nil {
+		return
 	}
 
-	sortStage := bson.D{{Key: "$sort", Value: bson.D{{Key: "votes", Value: -1}}}}
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
 
-	pipeline := mongo.Pipeline{lookupAuthorStage, lookupBoardStage, projectFieldsStage, sortStage}
+	var boards []primitive.ObjectID
+	if value, ok := c.Get(requesterKey); ok {
+		subs, err := subscriptions.Of(ctx, value.(types.User).ID)
+		if err != nil {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrInternal,
+				"failed listing subscriptions",
+				"GetFeed", err,
+			))
+			return
+		}
+		for _, sub := range subs {
+			boards = append(boards, sub.Board)
+		}
+	}
 
-	cursor, err := posts.Aggregate(ctx, pipeline)
+	var feed []types.NicePost
+	if len(boards) > 0 {
+		feed, err = posts.Feed(ctx, boards, p.query())
+	} else {
+		feed, err = posts.Popular(ctx, p.query())
+	}
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
 			"connection failed",
+			"GetFeed", err,
 		))
 		return
 	}
 
-	var postssss []types.NicePost
-	err = cursor.All(ctx, &postssss)
+	c.JSON(http.StatusOK, struct {
+		pageResponse
+		Personalized bool `json:"personalized"`
+	}{
+		pageResponse: pageOf(c, p, feed, nicePostKey(p)),
+		Personalized: len(boards) > 0,
+	})
+}
+
+func NewPost(c *gin.Context, posts storage.PostStore, boards storage.BoardStore, users storage.UserStore, library *media.Library, notifier *notify.Notifier, events live.Publisher) {
+	boardId, err := idFromParams(c)
 	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"connection failed",
-		))
 		return
 	}
 
-	c.JSON(http.StatusOK, postssss)
-}
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
 
-func NewPost(c *gin.Context, posts *mongo.Collection) {
 	body := struct {
-		Post      types.Post        `json:"post"`
-		Requester types.Credentials `json:"requester"`
+		Post types.Post `json:"post"`
 	}{}
 
-	err := decodeBody(c, &body)
+	err = decodeBody(c, &body)
 	if err != nil {
 		return
 	}
 
-	if err := body.Requester.Authorize(); err != nil {
+	if !body.Post.Board.IsZero() && body.Post.Board != boardId {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotAuthorized,
-			"user not authorized",
-			"error", err,
+			msgs.ErrWrongFormat,
+			"post board doesn't match the board in the path",
 		))
 		return
 	}
 
-	usr, err := body.Requester.ToUser()
-	if err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	_, err = boards.Get(ctx, boardId)
+	if err == storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"board not found",
+		))
+		return
+	} else if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
-			"skill issue",
-			"message", err,
+			"board finding skill issue",
 		))
 		return
 	}
 
+	body.Post.Board = boardId
 	body.Post.Author = usr.ID
+	body.Post.Votes, body.Post.Ups, body.Post.Downs, body.Post.Controversy = 0, 0, 0, 0
+	body.Post.Rising, body.Post.Comments = 0, 0
+	body.Post.Deletion = types.Deletion{}
+	body.Post.Edit = types.Edit{}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
+	mentioned, ok := validContent(ctx, c, posts, users, boards, library, boardId, &body.Post, primitive.NilObjectID)
+	if !ok {
+		return
+	}
 
-	result, optionsErr := posts.InsertOne(ctx, body.Post)
-	if optionsErr != nil {
+	id, err := posts.Create(ctx, body.Post)
+	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrBadOptions,
-			"Bad options provided in the InsertOne",
-			optionsErr,
+			"failed creating the post",
+			"NewPost", err,
 		))
 		return
 	}
 
+	body.Post.ID = id
+	notifier.Post(body.Post, mentioned)
+	events.Publish(live.New(live.PostCreated, body.Post, live.Board(boardId)))
+
 	c.JSON(http.StatusCreated, struct {
 		Code   int    `json:"code"`
 		Status string `json:"status"`
@@ -127,27 +168,75 @@ func NewPost(c *gin.Context, posts *mongo.Collection) {
 	}{
 		Code:   http.StatusCreated,
 		Status: "OK",
-		ID:     result.InsertedID.(primitive.ObjectID).String(),
+		ID:     id.String(),
 	})
 }
 
-func GetPost(c *gin.Context, posts *mongo.Collection) {
-	boardId, postId, err := postId(c)
+// validContent normalizes the post for its body type, renders text posts,
+// points image posts at stored media and rejects links the board already has
+// in another live post, self is the post being updated. Text posts also
+// return the users they mention.
+func validContent(ctx context.Context, c *gin.Context, posts storage.PostStore, users storage.UserStore, boards storage.BoardStore, library *media.Library, boardId primitive.ObjectID, post *types.Post, self primitive.ObjectID) ([]primitive.ObjectID, bool) {
+	err := content.Normalize(post)
 	if err != nil {
-		return
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrWrongFormat,
+			err.Error(),
+		))
+		return nil, false
 	}
 
-	filter := bson.M{
-		"_id":   postId,
-		"board": boardId,
+	post.BodyHTML, post.Image = "", nil
+	if post.BodyType == types.Text {
+		rendered, mentioned, ok := renderMarkdown(ctx, c, users, boards, post.BodyContent)
+		post.BodyHTML = rendered
+		return mentioned, ok
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	if post.BodyType == types.Image {
+		image, ok := resolveImage(c, library, post.BodyContent, post.Author)
+		if !ok {
+			return nil, false
+		}
+		post.BodyContent = image.ID
+		post.Image = &image.ImageInfo
+		return nil, true
+	}
+
+	if post.BodyType != types.Link {
+		return nil, true
+	}
+
+	existing, err := posts.ByLink(ctx, boardId, post.BodyContent)
+	if err == storage.ErrNotFound || (err == nil && existing.ID == self) {
+		return nil, true
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed checking the link",
+			"validContent", err,
+		))
+		return nil, false
+	}
+
+	c.AbortWithStatusJSON(msgs.ReportError(
+		msgs.ErrDuplicateLink,
+		"the link was already posted to this board as "+existing.ID.Hex(),
+	))
+	return nil, false
+}
+
+func GetPost(c *gin.Context, posts storage.PostStore, votes storage.VoteStore) {
+	boardId, postId, err := postId(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
 	defer cancel()
 
-	var post types.Post
-	err = posts.FindOne(ctx, filter).Decode(&post)
-	if err == mongo.ErrNoDocuments {
+	post, err := posts.Get(ctx, boardId, postId)
+	if err == storage.ErrNotFound {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrNotFound,
 			"post not found!",
@@ -162,71 +251,70 @@ func GetPost(c *gin.Context, posts *mongo.Collection) {
 		return
 	}
 
+	post.MyVote = myVotes(c, votes, []primitive.ObjectID{post.ID})[post.ID]
+
 	c.JSON(http.StatusOK, post)
 }
 
-func GetPosts(c *gin.Context, posts *mongo.Collection) {
+func GetPosts(c *gin.Context, posts storage.PostStore, votes storage.VoteStore) {
 	boardId, err := idFromParams(c)
 	if err != nil {
 		return
 	}
 
-	filter := bson.M{
-		"board": boardId,
+	p, err := postParams(c, sortNew, sortHot, sortRising, sortTop, sortControversial)
+	if err != nil {
+		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
 	defer cancel()
 
-	cursor, err := posts.Find(ctx, filter)
+	results, err := posts.List(ctx, boardId, p.query())
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
 			"skill issue",
+			"GetPosts", err,
 		))
 		return
 	}
 
-	var results []types.Post
-	err = cursor.All(ctx, &results)
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"Failed decoding cursor",
-			"GetPosts cursor", err,
-		))
-		return
+	ids := make([]primitive.ObjectID, len(results))
+	for i, p := range results {
+		ids[i] = p.ID
+	}
+	mine := myVotes(c, votes, ids)
+	for i := range results {
+		results[i].MyVote = mine[results[i].ID]
 	}
 
-	c.JSON(http.StatusOK, results)
+	respondPage(c, p, results, postKey(p))
 }
 
-func UpdatePost(c *gin.Context, posts *mongo.Collection, boards *mongo.Collection, users *mongo.Collection) {
+func UpdatePost(c *gin.Context, posts storage.PostStore, boards storage.BoardStore, users storage.UserStore, revisions storage.RevisionStore, library *media.Library, notifier *notify.Notifier) {
 	boardId, postId, err := postId(c)
 	if err != nil {
 		return
 	}
 
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
 	var bdy struct {
-		Post      types.Post        `json:"post"`
-		Requester types.Credentials `json:"requester"`
+		Post types.Post `json:"post"`
 	}
 	err = decodeBody(c, &bdy)
 	if err != nil {
 		return
 	}
 
-	if err := bdy.Requester.Authorize(); err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotAuthorized,
-			"user not authorized",
-			"error", err,
-		))
-		return
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
 
-	var post types.Post
-	err = getAndConvert(posts, postId, &post)
+	post, err := posts.Get(ctx, boardId, postId)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrNotFound,
@@ -235,8 +323,7 @@ func UpdatePost(c *gin.Context, posts *mongo.Collection, boards *mongo.Collectio
 		return
 	}
 
-	var board types.Board
-	err = getAndConvert(boards, boardId, &board)
+	board, err := boards.Get(ctx, boardId)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrNotFound,
@@ -245,16 +332,6 @@ func UpdatePost(c *gin.Context, posts *mongo.Collection, boards *mongo.Collectio
 		return
 	}
 
-	usr, err := bdy.Requester.ToUser()
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotFound,
-			"skill issue finding user",
-			"error", err,
-		))
-		return
-	}
-
 	if !(types.IsAdmin(usr) || types.IsModerator(board, usr) || post.Author == usr.ID) {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrForbidden,
@@ -264,29 +341,34 @@ func UpdatePost(c *gin.Context, posts *mongo.Collection, boards *mongo.Collectio
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
-
-	update := bson.M{"$set": bdy.Post}
+	bdy.Post.Author, bdy.Post.Board = post.Author, post.Board
+	bdy.Post.Deletion = types.Deletion{}
 
-	updateResult, err := posts.UpdateByID(ctx, postId, update)
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrBadOptions,
-			"options failure",
-			"UpdatePost", err,
-		))
+	if _, ok := validContent(ctx, c, posts, users, boards, library, boardId, &bdy.Post, postId); !ok {
+		return
+	}
+	if !revisePost(ctx, c, revisions, usr.ID, post, &bdy.Post) {
 		return
 	}
 
-	if updateResult.ModifiedCount == 0 {
+	err = posts.Update(ctx, postId, bdy.Post)
+	if err == storage.ErrNotFound || err == storage.ErrNotModified {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrUpdateFailed,
 			"failed to update the board",
-			"UpdateBoard", updateResult,
+			"UpdateBoard", err,
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrBadOptions,
+			"options failure",
+			"UpdatePost", err,
 		))
 		return
 	}
+	notifier.Moderated(usr.ID, post.Author, boardId, postId, nil, types.ActionEdited)
+
 	c.JSON(http.StatusAccepted, struct {
 		Code   int    `json:"code"`
 		Status string `json:"status"`
@@ -296,56 +378,36 @@ func UpdatePost(c *gin.Context, posts *mongo.Collection, boards *mongo.Collectio
 	})
 }
 
-func DeletePost(c *gin.Context, posts *mongo.Collection, boards *mongo.Collection, users *mongo.Collection) {
+// PatchPost changes the title and body of the post in the merge patch body
+// and answers with the post.
+func PatchPost(c *gin.Context, posts storage.PostStore, boards storage.BoardStore, users storage.UserStore, revisions storage.RevisionStore, library *media.Library, notifier *notify.Notifier) {
 	boardId, postId, err := postId(c)
 	if err != nil {
 		return
 	}
 
-	var bdy struct {
-		Requester types.Credentials `json:"requester"`
-	}
-	err = decodeBody(c, &bdy)
+	usr, err := requester(c)
 	if err != nil {
 		return
 	}
 
-	if err := bdy.Requester.Authorize(); err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotAuthorized,
-			"user not authorized",
-			"error", err,
-		))
-		return
-	}
-
-	var post types.Post
-	err = getAndConvert(posts, postId, &post)
-	if err != nil {
-		log.Fatal("HERE")
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			err.Error(),
-		))
-		return
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
 
-	var board types.Board
-	err = getAndConvert(boards, boardId, &board)
+	post, err := posts.Get(ctx, boardId, postId)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrNotFound,
-			"skill issue finding board",
+			"post finding skill issue",
 		))
 		return
 	}
 
-	usr, err := bdy.Requester.ToUser()
+	board, err := boards.Get(ctx, boardId)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrNotFound,
-			"skill issue finding user",
-			"error", err,
+			"board finding skill issue",
 		))
 		return
 	}
@@ -354,81 +416,123 @@ func DeletePost(c *gin.Context, posts *mongo.Collection, boards *mongo.Collectio
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrForbidden,
 			"action is forbidden!",
-			"UpdatePost", "is neither an admin, moderator nor owner",
+			"PatchPost", "is neither an admin, moderator nor owner",
 		))
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
+	patched := post
+	touched, ok := mergePatch(c, patchable{
+		"title":       &patched.Title,
+		"bodyType":    &patched.BodyType,
+		"bodyContent": &patched.BodyContent,
+	})
+	if !ok {
+		return
+	}
+	if len(touched) == 0 {
+		c.JSON(http.StatusOK, post)
+		return
+	}
 
-	deleteResult, err := posts.DeleteOne(ctx, bson.M{"_id": postId})
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrBadOptions,
-			"skill issue",
-		))
+	if _, ok := validContent(ctx, c, posts, users, boards, library, boardId, &patched, postId); !ok {
+		return
+	}
+	if !revisePost(ctx, c, revisions, usr.ID, post, &patched) {
 		return
 	}
 
-	if deleteResult.DeletedCount != 1 {
+	err = posts.Update(ctx, postId, patched)
+	if err == storage.ErrNotModified {
+		c.JSON(http.StatusOK, post)
+		return
+	} else if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotFound,
-			"post failed to delete",
-			"DeleteUser", deleteResult.DeletedCount != 1,
+			msgs.ErrBadOptions,
+			"options failure",
+			"PatchPost", err,
 		))
 		return
 	}
+	notifier.Moderated(usr.ID, post.Author, boardId, postId, nil, types.ActionEdited)
+	if stored, err := posts.Get(ctx, boardId, postId); err == nil {
+		patched = stored
+	}
 
-	c.JSON(http.StatusOK, struct {
-		Code   int    `json:"code"`
-		Status string `json:"status"`
-	}{
-		Code:   http.StatusOK,
-		Status: "OK",
-	})
+	c.JSON(http.StatusOK, patched)
 }
 
-func SearchPost(c *gin.Context, posts *mongo.Collection) {
-	var length int
-	for _, v := range c.Request.URL.Query() {
-		length += len(v)
+func DeletePost(c *gin.Context, posts storage.PostStore, boards storage.BoardStore, notifier *notify.Notifier, events live.Publisher) {
+	boardId, postId, err := postId(c)
+	if err != nil {
+		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
 
-	var wg sync.WaitGroup
-	ch := make(chan findResultPosts, length)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Write)
+	defer cancel()
 
-	for k, s := range c.Request.URL.Query() {
-		for _, v := range s {
-			wg.Add(1)
-			go findByFieldPosts(ctx, posts, k, v, ch, &wg)
-		}
+	post, err := posts.Get(ctx, boardId, postId)
+	if err == storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"post not found!",
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			err.Error(),
+		))
+		return
 	}
 
-	wg.Wait()
-	close(ch)
+	board, err := boards.Get(ctx, boardId)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"skill issue finding board",
+		))
+		return
+	}
 
-	var values []types.Post
-	for v := range ch {
-		if err := v.err; err != nil {
-			log.Debug(msgs.DebugSkippedLoop, "struct", v)
-			continue
-		}
-		log.Debug("appending", "values +", v)
-		values = append(values, v.posts...)
+	if !(types.IsAdmin(usr) || types.IsModerator(board, usr) || post.Author == usr.ID) {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrForbidden,
+			"action is forbidden!",
+			"UpdatePost", "is neither an admin, moderator nor owner",
+		))
+		return
 	}
 
-	if len(values) == 0 {
+	err = posts.SoftDelete(ctx, boardId, postId, usr.ID)
+	if err == storage.ErrNotFound {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrNotFound,
-			"no posts found with provided parameters",
-			"values", values,
+			"post failed to delete",
+			"DeletePost", err,
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed deleting the post",
+			"DeletePost", err,
 		))
 		return
 	}
+	notifier.Moderated(usr.ID, post.Author, boardId, postId, nil, types.ActionDeleted)
+	events.Publish(live.New(live.PostDeleted, liveTarget{ID: postId, Board: boardId}, live.Board(boardId), live.Post(postId)))
 
-	c.JSON(http.StatusOK, values)
+	c.JSON(http.StatusOK, struct {
+		Code   int    `json:"code"`
+		Status string `json:"status"`
+	}{
+		Code:   http.StatusOK,
+		Status: "OK",
+	})
 }
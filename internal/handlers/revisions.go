@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"redoot/internal/diff"
+	"redoot/internal/msgs"
+	"redoot/internal/storage"
+	"redoot/internal/types"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// original is the content as it was posted, before any edit.
+type original struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body"`
+}
+
+type revisionsResponse struct {
+	pageResponse
+	Original original `json:"original"`
+}
+
+func revisionKey(r types.Revision) (float64, primitive.ObjectID) {
+	return 0, r.ID
+}
+
+// revisePost keeps the post as it was before the edit and marks the edited
+// one, edits leaving the content alone keep no revision.
+func revisePost(ctx context.Context, c *gin.Context, revisions storage.RevisionStore, editor primitive.ObjectID, old types.Post, edited *types.Post) bool {
+	edited.Edit = old.Edit
+	if old.Title == edited.Title && old.BodyType == edited.BodyType && old.BodyContent == edited.BodyContent {
+		return true
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	edited.Edit = types.Edit{Edited: true, EditedAt: &now}
+	return keepRevision(ctx, c, revisions, types.Revision{
+		Post:      old.ID,
+		Editor:    editor,
+		Title:     old.Title,
+		Body:      old.BodyContent,
+		Diff:      diff.Lines(old.BodyContent, edited.BodyContent),
+		CreatedAt: now,
+	})
+}
+
+// reviseComment is revisePost for comments.
+func reviseComment(ctx context.Context, c *gin.Context, revisions storage.RevisionStore, editor primitive.ObjectID, old types.Comment, edited *types.Comment) bool {
+	edited.Edit = old.Edit
+	if old.Body == edited.Body {
+		return true
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	edited.Edit = types.Edit{Edited: true, EditedAt: &now}
+	return keepRevision(ctx, c, revisions, types.Revision{
+		Post:      old.Post,
+		Comment:   &old.ID,
+		Editor:    editor,
+		Body:      old.Body,
+		Diff:      diff.Lines(old.Body, edited.Body),
+		CreatedAt: now,
+	})
+}
+
+// keepRevision is stored before the edit itself, an edit without its
+// revision would lose the previous content for good.
+func keepRevision(ctx context.Context, c *gin.Context, revisions storage.RevisionStore, revision types.Revision) bool {
+	err := revisions.Create(ctx, revision)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed keeping the previous revision",
+			"keepRevision", err,
+		))
+		return false
+	}
+	return true
+}
+
+func removed(deletions ...types.Deletion) bool {
+	for _, d := range deletions {
+		if d.DeletedAt != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// canSeeRemoved reports whether the requester moderates the board, only
+// they get to see the history of removed content.
+func canSeeRemoved(c *gin.Context, board types.Board) bool {
+	value, ok := c.Get(requesterKey)
+	if !ok {
+		return false
+	}
+	usr := value.(types.User)
+	return types.IsAdmin(usr) || types.IsModerator(board, usr) || board.Owner == usr.ID
+}
+
+// GetPostRevisions lists the edits of the post newest first along with its
+// original content, removed posts only to the moderators of the board.
+func GetPostRevisions(c *gin.Context, boards storage.BoardStore, posts storage.PostStore, revisions storage.RevisionStore) {
+	boardId, postId, err := postId(c)
+	if err != nil {
+		return
+	}
+
+	p, err := pageParams(c, sortNew)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	board, err := boards.GetAny(ctx, boardId)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"board not found",
+		))
+		return
+	}
+
+	post, err := posts.GetAny(ctx, boardId, postId)
+	if err != nil || (removed(board.Deletion, post.Deletion) && !canSeeRemoved(c, board)) {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"post not found!",
+		))
+		return
+	}
+
+	history, err := revisions.List(ctx, postId, nil, p.query())
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed listing revisions",
+			"GetPostRevisions", err,
+		))
+		return
+	}
+
+	first := original{Title: post.Title, Body: post.BodyContent}
+	oldest, err := revisions.First(ctx, postId, nil)
+	if err == nil {
+		first = original{Title: oldest.Title, Body: oldest.Body}
+	} else if err != storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed finding the original",
+			"GetPostRevisions", err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, revisionsResponse{
+		pageResponse: pageOf(c, p, history, revisionKey),
+		Original:     first,
+	})
+}
+
+// GetCommentRevisions is GetPostRevisions for comments, a comment under a
+// removed post counts as removed.
+func GetCommentRevisions(c *gin.Context, boards storage.BoardStore, posts storage.PostStore, comments storage.CommentStore, revisions storage.RevisionStore) {
+	boardId, postId, commentId, err := commentIdParams(c)
+	if err != nil {
+		return
+	}
+
+	p, err := pageParams(c, sortNew)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	board, err := boards.GetAny(ctx, boardId)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"board not found",
+		))
+		return
+	}
+
+	post, err := posts.GetAny(ctx, boardId, postId)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"post not found!",
+		))
+		return
+	}
+
+	comment, err := comments.GetAny(ctx, postId, commentId)
+	if err != nil || comment.Purged || (removed(board.Deletion, post.Deletion, comment.Deletion) && !canSeeRemoved(c, board)) {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"comment not found",
+		))
+		return
+	}
+
+	history, err := revisions.List(ctx, postId, &commentId, p.query())
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed listing revisions",
+			"GetCommentRevisions", err,
+		))
+		return
+	}
+
+	first := original{Body: comment.Body}
+	oldest, err := revisions.First(ctx, postId, &commentId)
+	if err == nil {
+		first = original{Body: oldest.Body}
+	} else if err != storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed finding the original",
+			"GetCommentRevisions", err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, revisionsResponse{
+		pageResponse: pageOf(c, p, history, revisionKey),
+		Original:     first,
+	})
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"redoot/internal/msgs"
+	"redoot/internal/storage"
+	"redoot/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetRoles(c *gin.Context, roles storage.RoleStore) {
+	objid, err := idFromParams(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	held, err := roles.Of(ctx, objid)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed getting roles",
+			"GetRoles", err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, held)
+}
+
+func GrantRole(c *gin.Context, users storage.UserStore, roles storage.RoleStore) {
+	objid, err := idFromParams(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	if !types.IsAdmin(usr) {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrForbidden,
+			"only admins can grant roles",
+		))
+		return
+	}
+
+	var body struct {
+		Role types.RoleName `json:"role"`
+	}
+	err = decodeBody(c, &body)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	_, err = users.Get(ctx, objid)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"user not found",
+			"GrantRole", err,
+		))
+		return
+	}
+
+	err = roles.Grant(ctx, objid, body.Role, usr.ID)
+	if err == types.ErrUnknownRole {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrWrongFormat,
+			"role must be one of admin, moderator",
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed granting role",
+			"GrantRole", err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusCreated, struct {
+		Code   int    `json:"code"`
+		Status string `json:"status"`
+	}{
+		Code:   http.StatusCreated,
+		Status: "OK",
+	})
+}
+
+func RevokeRole(c *gin.Context, roles storage.RoleStore) {
+	objid, err := idFromParams(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	if !types.IsAdmin(usr) {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrForbidden,
+			"only admins can revoke roles",
+		))
+		return
+	}
+
+	role := types.RoleName(c.Param("role"))
+	if role == types.RoleAdmin && objid == usr.ID {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrForbidden,
+			"admins can't revoke their own admin role",
+		))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	err = roles.Revoke(ctx, objid, role, usr.ID)
+	if err == types.ErrUnknownRole {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrWrongFormat,
+			"role must be one of admin, moderator",
+		))
+		return
+	} else if err == storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"user doesn't hold the role",
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed revoking role",
+			"RevokeRole", err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, struct {
+		Code   int    `json:"code"`
+		Status string `json:"status"`
+	}{
+		Code:   http.StatusOK,
+		Status: "OK",
+	})
+}
+
+func GetRoleAudit(c *gin.Context, roles storage.RoleStore) {
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	if !types.IsAdmin(usr) {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrForbidden,
+			"only admins can access this feature",
+		))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	entries, err := roles.Audit(ctx)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed getting role audit",
+			"GetRoleAudit", err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
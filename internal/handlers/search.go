@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"context"
+	"redoot/internal/msgs"
+	"redoot/internal/search"
+	"redoot/internal/storage"
+	"redoot/internal/types"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	searchPost    = "post"
+	searchComment = "comment"
+	searchBoard   = "board"
+	searchUser    = "user"
+)
+
+// snippetWidth is roughly how many characters of the matching text a hit
+// shows.
+const snippetWidth = 160
+
+var searchTypes = []string{searchPost, searchComment, searchBoard, searchUser}
+
+// searchScope is what the path narrows a search down to, kinds are the types
+// that can be found there.
+type searchScope struct {
+	kinds []string
+	board primitive.ObjectID
+	post  primitive.ObjectID
+}
+
+// searchHit only carries public fields, whatever type it was found in.
+type searchHit struct {
+	Type    string  `json:"type"`
+	ID      string  `json:"id"`
+	Score   float64 `json:"score"`
+	Title   string  `json:"title"`
+	Board   string  `json:"board,omitempty"`
+	Post    string  `json:"post,omitempty"`
+	Snippet string  `json:"snippet"`
+	id      primitive.ObjectID
+}
+
+func hitKey(h searchHit) (float64, primitive.ObjectID) {
+	return h.Score, h.id
+}
+
+// snippetOf cuts the snippet from the first field mentioning a term, the
+// title is already in the hit so it's only the fallback.
+func snippetOf(terms []string, title string, texts ...string) string {
+	for _, text := range texts {
+		if search.Score(terms, search.Field{Text: text, Weight: 1}) > 0 {
+			return search.Snippet(text, terms, snippetWidth)
+		}
+	}
+	return search.Snippet(title, terms, snippetWidth)
+}
+
+// searchTypesParam reads the comma separated types, all of the allowed ones
+// when none are given.
+func searchTypesParam(c *gin.Context, allowed []string) (map[string]bool, error) {
+	wanted := map[string]bool{}
+	for _, param := range c.QueryArray("type") {
+		for _, kind := range strings.Split(param, ",") {
+			kind = strings.TrimSpace(kind)
+			if kind == "" {
+				continue
+			}
+			if !slices.Contains(allowed, kind) {
+				c.AbortWithStatusJSON(msgs.ReportError(
+					msgs.ErrWrongFormat,
+					"type must be one of "+strings.Join(allowed, ", "),
+					"type", kind,
+				))
+				return nil, msgs.ErrWrongFormat
+			}
+			wanted[kind] = true
+		}
+	}
+
+	if len(wanted) == 0 {
+		for _, kind := range allowed {
+			wanted[kind] = true
+		}
+	}
+	return wanted, nil
+}
+
+// queryParam parses q, syntax errors point at the offending part.
+func queryParam(c *gin.Context) (search.Query, error) {
+	q, err := search.Parse(c.Query("q"))
+	if syntax, ok := err.(*search.SyntaxError); ok {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrQuerySyntax,
+			syntax.Error(),
+			"position", syntax.Pos,
+			"token", syntax.Token,
+		))
+		return search.Query{}, err
+	}
+	return q, err
+}
+
+// resolveQuery looks up the author and board the query names, by id or by
+// name. found is false when one of them doesn't exist so nothing can match.
+func resolveQuery(ctx context.Context, q *search.Query, users storage.UserStore, boards storage.BoardStore) (found bool, err error) {
+	if q.Author != "" {
+		if id, err := primitive.ObjectIDFromHex(q.Author); err == nil {
+			q.AuthorID = id
+		} else {
+			usr, err := users.ByName(ctx, q.Author)
+			if err == storage.ErrNotFound {
+				return false, nil
+			} else if err != nil {
+				return false, err
+			}
+			q.AuthorID = usr.ID
+		}
+	}
+
+	if q.Board != "" {
+		if id, err := primitive.ObjectIDFromHex(q.Board); err == nil {
+			q.BoardID = id
+		} else {
+			named, err := boards.Search(ctx, "name", "^"+regexp.QuoteMeta(q.Board)+"$")
+			if err != nil {
+				return false, err
+			}
+			if len(named) == 0 {
+				return false, nil
+			}
+			q.BoardID = named[0].ID
+		}
+	}
+	return true, nil
+}
+
+// Search runs a query over the whole site.
+func Search(c *gin.Context, users storage.UserStore, boards storage.BoardStore, posts storage.PostStore, comments storage.CommentStore) {
+	runSearch(c, searchScope{kinds: searchTypes}, users, boards, posts, comments)
+}
+
+// SearchInBoard runs a query over the posts and comments of the board.
+func SearchInBoard(c *gin.Context, users storage.UserStore, boards storage.BoardStore, posts storage.PostStore, comments storage.CommentStore) {
+	boardId, err := idFromParams(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	_, err = boards.Get(ctx, boardId)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"board not found",
+		))
+		return
+	}
+
+	scope := searchScope{kinds: []string{searchPost, searchComment}, board: boardId}
+	runSearch(c, scope, users, boards, posts, comments)
+}
+
+// SearchInPost runs a query over the comments of the post.
+func SearchInPost(c *gin.Context, users storage.UserStore, boards storage.BoardStore, posts storage.PostStore, comments storage.CommentStore) {
+	boardId, postId, err := postId(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	_, err = posts.Get(ctx, boardId, postId)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"post not found!",
+		))
+		return
+	}
+
+	scope := searchScope{kinds: []string{searchComment}, board: boardId, post: postId}
+	runSearch(c, scope, users, boards, posts, comments)
+}
+
+// runSearch merges the hits of every wanted type by relevance into one page.
+// Filters only posts and comments have leave out boards and users, the path
+// takes precedence over a board in the query.
+func runSearch(c *gin.Context, scope searchScope, users storage.UserStore, boards storage.BoardStore, posts storage.PostStore, comments storage.CommentStore) {
+	q, err := queryParam(c)
+	if err != nil {
+		return
+	}
+	terms := q.Highlights()
+
+	if !scope.board.IsZero() {
+		q.Board = scope.board.Hex()
+	}
+	q.PostID = scope.post
+
+	wanted, err := searchTypesParam(c, scope.kinds)
+	if err != nil {
+		return
+	}
+	if q.ContentOnly() {
+		wanted[searchBoard], wanted[searchUser] = false, false
+	}
+	if q.Type != nil {
+		wanted[searchComment] = false
+	}
+
+	p, err := pageParams(c, sortRelevance)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	hits := []searchHit{}
+
+	found, err := resolveQuery(ctx, &q, users, boards)
+	if err != nil {
+		reportSearchError(c, "resolveQuery", err)
+		return
+	} else if !found {
+		respondPage(c, p, hits, hitKey)
+		return
+	}
+
+	if wanted[searchPost] {
+		found, err := posts.Text(ctx, q, p.query())
+		if err != nil {
+			reportSearchError(c, searchPost, err)
+			return
+		}
+		for _, s := range found {
+			body := s.Doc.BodyContent
+			if s.Doc.BodyType != types.Text {
+				body = ""
+			}
+			hits = append(hits, searchHit{
+				Type:    searchPost,
+				ID:      s.Doc.ID.Hex(),
+				Score:   s.Score,
+				Title:   s.Doc.Title,
+				Board:   s.Doc.Board.Hex(),
+				Snippet: snippetOf(terms, s.Doc.Title, body),
+				id:      s.Doc.ID,
+			})
+		}
+	}
+
+	if wanted[searchComment] {
+		found, err := comments.Text(ctx, q, p.query())
+		if err != nil {
+			reportSearchError(c, searchComment, err)
+			return
+		}
+
+		ids := []primitive.ObjectID{}
+		for _, s := range found {
+			ids = append(ids, s.Doc.Post)
+		}
+		parents, err := posts.Lookup(ctx, ids)
+		if err != nil {
+			reportSearchError(c, searchComment, err)
+			return
+		}
+
+		for _, s := range found {
+			parent := parents[s.Doc.Post]
+			hits = append(hits, searchHit{
+				Type:    searchComment,
+				ID:      s.Doc.ID.Hex(),
+				Score:   s.Score,
+				Title:   parent.Title,
+				Board:   parent.Board.Hex(),
+				Post:    parent.ID.Hex(),
+				Snippet: snippetOf(terms, "", s.Doc.Body),
+				id:      s.Doc.ID,
+			})
+		}
+	}
+
+	if wanted[searchBoard] {
+		found, err := boards.Text(ctx, q, p.query())
+		if err != nil {
+			reportSearchError(c, searchBoard, err)
+			return
+		}
+		for _, s := range found {
+			hits = append(hits, searchHit{
+				Type:    searchBoard,
+				ID:      s.Doc.ID.Hex(),
+				Score:   s.Score,
+				Title:   s.Doc.Name,
+				Snippet: snippetOf(terms, s.Doc.Name, s.Doc.Bio, s.Doc.Rules),
+				id:      s.Doc.ID,
+			})
+		}
+	}
+
+	if wanted[searchUser] {
+		found, err := users.Text(ctx, q, p.query())
+		if err != nil {
+			reportSearchError(c, searchUser, err)
+			return
+		}
+		for _, s := range found {
+			hits = append(hits, searchHit{
+				Type:    searchUser,
+				ID:      s.Doc.ID.Hex(),
+				Score:   s.Score,
+				Title:   s.Doc.Name,
+				Snippet: snippetOf(terms, s.Doc.Name, s.Doc.Bio),
+				id:      s.Doc.ID,
+			})
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].id.Hex() > hits[j].id.Hex()
+	})
+
+	hits = unique(hits, func(h searchHit) primitive.ObjectID { return h.id })
+	if int64(len(hits)) > p.limit+1 {
+		hits = hits[:p.limit+1]
+	}
+	respondPage(c, p, hits, hitKey)
+}
+
+func reportSearchError(c *gin.Context, kind string, err error) {
+	c.AbortWithStatusJSON(msgs.ReportError(
+		msgs.ErrInternal,
+		"failed searching",
+		kind, err,
+	))
+}
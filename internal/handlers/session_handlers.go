@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"redoot/internal/auth"
+	"redoot/internal/msgs"
+	"redoot/internal/storage"
+	"redoot/internal/types"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const requesterKey = "requester"
+const sessionKey = "session"
+
+type sessionResponse struct {
+	Code           int       `json:"code"`
+	Status         string    `json:"status"`
+	Token          string    `json:"token"`
+	Expires        time.Time `json:"expires"`
+	RefreshToken   string    `json:"refreshToken"`
+	RefreshExpires time.Time `json:"refreshExpires"`
+}
+
+// Authenticate resolves the bearer token into the requesting user, requests
+// without the Authorization header pass through as anonymous.
+func Authenticate(sessions storage.SessionStore, users storage.UserStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrNotAuthorized,
+				"expected a bearer token",
+			))
+			return
+		}
+
+		claims, err := auth.Verify(token)
+		if err != nil {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrNotAuthorized,
+				err.Error(),
+				"Authenticate", err,
+			))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+		defer cancel()
+
+		session, err := sessions.Get(ctx, claims.Session)
+		if err != nil {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrNotAuthorized,
+				"session revoked",
+				"Authenticate", err,
+			))
+			return
+		}
+
+		usr, err := users.Get(ctx, claims.User)
+		if err != nil {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrNotAuthorized,
+				"user no longer exists",
+				"Authenticate", err,
+			))
+			return
+		}
+
+		c.Set(requesterKey, usr)
+		c.Set(sessionKey, session)
+		c.Next()
+	}
+}
+
+func issueSession(c *gin.Context, session types.Session, refresh string, status int) {
+	token, expires, err := auth.NewAccessToken(session.ID, session.User)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed signing the token",
+			"issueSession", err,
+		))
+		return
+	}
+
+	c.JSON(status, sessionResponse{
+		Code:           status,
+		Status:         "OK",
+		Token:          token,
+		Expires:        expires,
+		RefreshToken:   refresh,
+		RefreshExpires: session.RefreshExpires,
+	})
+}
+
+func NewSession(c *gin.Context, sessions storage.SessionStore, users storage.UserStore) {
+	var creds types.Credentials
+	err := decodeBody(c, &creds)
+	if err != nil {
+		return
+	}
+
+	lookup, cancelLookup := context.WithTimeout(context.Background(), timeouts.Query)
+	usr, err := users.ByName(lookup, creds.Name)
+	cancelLookup()
+	// comparing the password is slow on purpose, it runs outside of the
+	// query timeouts
+	if err == nil {
+		err = creds.Authorize(usr)
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotAuthorized,
+			"user not authorized",
+			"error", err,
+		))
+		return
+	}
+
+	refresh, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed generating refresh token",
+			"error", err,
+		))
+		return
+	}
+
+	session := types.Session{
+		ID:             primitive.NewObjectID(),
+		User:           usr.ID,
+		RefreshHash:    hash,
+		RefreshExpires: time.Now().Add(auth.RefreshTokenTTL),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	err = sessions.Create(ctx, session)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrBadOptions,
+			"Bad options provided in the InsertOne",
+			err,
+		))
+		return
+	}
+
+	log.Debug("session created", "user", usr.Name, "session", session.ID)
+	issueSession(c, session, refresh, http.StatusCreated)
+}
+
+func RefreshSession(c *gin.Context, sessions storage.SessionStore) {
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	err := decodeBody(c, &body)
+	if err != nil {
+		return
+	}
+
+	refresh, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed generating refresh token",
+			"error", err,
+		))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	session, err := sessions.Rotate(ctx, auth.HashRefreshToken(body.RefreshToken), hash, time.Now().Add(auth.RefreshTokenTTL))
+	if err == storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotAuthorized,
+			"refresh token invalid or expired",
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed refreshing session",
+			"RefreshSession", err,
+		))
+		return
+	}
+
+	issueSession(c, session, refresh, http.StatusOK)
+}
+
+func DeleteSession(c *gin.Context, sessions storage.SessionStore) {
+	if _, err := requester(c); err != nil {
+		return
+	}
+
+	session := c.MustGet(sessionKey).(types.Session)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	err := sessions.Delete(ctx, session.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrBadOptions,
+			"failed revoking session",
+			"DeleteSession", err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, struct {
+		Code   int    `json:"code"`
+		Status string `json:"status"`
+	}{
+		Code:   http.StatusOK,
+		Status: "OK",
+	})
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"redoot/internal/live"
+	"redoot/internal/msgs"
+	"redoot/internal/storage"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// keepAlive is how often an idle stream sends a comment so proxies don't
+// time it out.
+const keepAlive = 25 * time.Second
+
+// liveTarget is the payload of deletion and vote events.
+type liveTarget struct {
+	ID    primitive.ObjectID  `json:"id"`
+	Board primitive.ObjectID  `json:"board"`
+	Post  *primitive.ObjectID `json:"post,omitempty"`
+	Votes *int                `json:"votes,omitempty"`
+}
+
+// StreamBoard sends the posts created, deleted and voted on in the board as
+// server-sent events.
+func StreamBoard(c *gin.Context, boards storage.BoardStore, hub *live.Hub) {
+	objid, err := idFromParams(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	_, err = boards.Get(ctx, objid)
+	if err == storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"board not found",
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"board finding skill issue",
+			"StreamBoard", err,
+		))
+		return
+	}
+	cancel()
+
+	stream(c, hub, live.Board(objid))
+}
+
+// StreamPost sends the votes and deletion of the post and the comments
+// created, deleted and voted on under it.
+func StreamPost(c *gin.Context, posts storage.PostStore, hub *live.Hub) {
+	boardId, postId, err := postId(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	_, err = posts.Get(ctx, boardId, postId)
+	if err == storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"post not found!",
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"an internal error has accured",
+			"StreamPost", err,
+		))
+		return
+	}
+	cancel()
+
+	stream(c, hub, live.Post(postId))
+}
+
+// StreamNotifications sends the notifications of the requester as they
+// arrive.
+func StreamNotifications(c *gin.Context, hub *live.Hub) {
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	stream(c, hub, live.User(usr.ID))
+}
+
+// stream writes the events of the topics until the client goes away or the
+// hub drops the subscription.
+func stream(c *gin.Context, hub *live.Hub, topics ...string) {
+	sub := hub.Subscribe(topics...)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			return true
+		case e, ok := <-sub.C:
+			if !ok {
+				return false
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, e.Data)
+			return true
+		}
+	})
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"redoot/internal/msgs"
+	"redoot/internal/notify"
+	"redoot/internal/storage"
+	"redoot/internal/types"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+)
+
+// trashRetention is how long soft deleted boards, posts and comments can be
+// restored before the reaper purges them.
+const trashRetention = time.Hour * 24 * 30
+
+type trashResponse struct {
+	Posts    []types.Post    `json:"posts"`
+	Comments []types.Comment `json:"comments"`
+}
+
+func retentionStart() time.Time {
+	return time.Now().UTC().Add(-trashRetention)
+}
+
+func respondRestore(c *gin.Context, err error, where string) {
+	if err == storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"nothing to restore, the retention window might have passed",
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed restoring",
+			where, err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, struct {
+		Code   int    `json:"code"`
+		Status string `json:"status"`
+	}{
+		Code:   http.StatusOK,
+		Status: "OK",
+	})
+}
+
+func RestoreBoard(c *gin.Context, boards storage.BoardStore) {
+	objid, err := idFromParams(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Write)
+	defer cancel()
+
+	board, err := boards.GetAny(ctx, objid)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"board not found",
+		))
+		return
+	}
+
+	if !(types.IsAdmin(usr) || types.IsModerator(board, usr) || board.Owner == usr.ID) {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrForbidden,
+			"action is forbidden!",
+			"RestoreBoard", "is neither an admin, moderator nor owner",
+		))
+		return
+	}
+
+	err = boards.Restore(ctx, objid, retentionStart())
+	respondRestore(c, err, "RestoreBoard")
+}
+
+func RestorePost(c *gin.Context, posts storage.PostStore, boards storage.BoardStore, notifier *notify.Notifier) {
+	boardId, postId, err := postId(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Write)
+	defer cancel()
+
+	board, err := boards.Get(ctx, boardId)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"board not found",
+		))
+		return
+	}
+
+	post, err := posts.GetAny(ctx, boardId, postId)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"post not found!",
+		))
+		return
+	}
+
+	if !(types.IsAdmin(usr) || types.IsModerator(board, usr) || post.Author == usr.ID) {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrForbidden,
+			"action is forbidden!",
+			"RestorePost", "is neither an admin, moderator nor author",
+		))
+		return
+	}
+
+	err = posts.Restore(ctx, boardId, postId, retentionStart())
+	if err == nil {
+		notifier.Moderated(usr.ID, post.Author, boardId, postId, nil, types.ActionRestored)
+	}
+	respondRestore(c, err, "RestorePost")
+}
+
+func RestoreComment(c *gin.Context, boards storage.BoardStore, posts storage.PostStore, comments storage.CommentStore, notifier *notify.Notifier) {
+	boardId, postId, commentId, err := commentIdParams(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	board, err := boards.Get(ctx, boardId)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"board not found",
+		))
+		return
+	}
+
+	_, err = posts.Get(ctx, boardId, postId)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"post not found!",
+		))
+		return
+	}
+
+	comment, err := comments.GetAny(ctx, postId, commentId)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"comment not found",
+		))
+		return
+	}
+
+	if !(types.IsAdmin(usr) || types.IsModerator(board, usr) || comment.Author == usr.ID) {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrForbidden,
+			"action is forbidden!",
+			"RestoreComment", "is neither an admin, moderator nor author",
+		))
+		return
+	}
+
+	err = comments.Restore(ctx, postId, commentId, retentionStart())
+	if err == nil {
+		notifier.Moderated(usr.ID, comment.Author, boardId, postId, &commentId, types.ActionRestored)
+	}
+	respondRestore(c, err, "RestoreComment")
+}
+
+// GetTrash lists what was deleted in the board and can still be restored,
+// newest deletions first.
+func GetTrash(c *gin.Context, boards storage.BoardStore, posts storage.PostStore, comments storage.CommentStore) {
+	objid, err := idFromParams(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	board, err := boards.Get(ctx, objid)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"board not found",
+		))
+		return
+	}
+
+	if !(types.IsAdmin(usr) || types.IsModerator(board, usr) || board.Owner == usr.ID) {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrForbidden,
+			"only moderators can see the trash",
+		))
+		return
+	}
+
+	var result trashResponse
+	result.Posts, err = posts.Trash(ctx, objid, retentionStart(), maxPageLimit)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed getting deleted posts",
+			"GetTrash", err,
+		))
+		return
+	}
+
+	result.Comments, err = comments.Trash(ctx, objid, retentionStart(), maxPageLimit)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed getting deleted comments",
+			"GetTrash", err,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Reap purges everything that was deleted longer than the retention window
+// ago. Boards go first so their posts and comments are purged with them.
+func Reap(boards storage.BoardStore, posts storage.PostStore, comments storage.CommentStore) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Reap)
+	defer cancel()
+
+	before := retentionStart()
+
+	purgedBoards, err := boards.Purge(ctx, before)
+	if err != nil {
+		return err
+	}
+
+	purgedPosts, err := posts.Purge(ctx, before)
+	if err != nil {
+		return err
+	}
+
+	purgedComments, err := comments.Purge(ctx, before)
+	if err != nil {
+		return err
+	}
+
+	log.Debug("reaped trash", "boards", purgedBoards, "posts", purgedPosts, "comments", purgedComments)
+	return nil
+}
+
+// Reaper runs Reap every interval until the process exits.
+func Reaper(interval time.Duration, boards storage.BoardStore, posts storage.PostStore, comments storage.CommentStore) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := Reap(boards, posts, comments); err != nil {
+			log.Error("Failed reaping trash", "reason", err)
+		}
+	}
+}
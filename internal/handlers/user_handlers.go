@@ -6,20 +6,20 @@ import (
 	"fmt"
 	"net/http"
 	"net/mail"
+	"redoot/internal/media"
 	"redoot/internal/msgs"
+	"redoot/internal/storage"
 	"redoot/internal/types"
+	"slices"
 	"sync"
-	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func NewUser(c *gin.Context, users *mongo.Collection) {
+func NewUser(c *gin.Context, users storage.UserStore, library *media.Library) {
 	body := struct {
 		User types.User `json:"user"`
 	}{}
@@ -39,15 +39,24 @@ func NewUser(c *gin.Context, users *mongo.Collection) {
 	}
 
 	usr := body.User
-	log.Debug(msgs.DebugStruct, "usr", fmt.Sprintf("%#v", usr))
+	log.Debug(msgs.DebugStruct, "usr", fmt.Sprintf("%#v", usr.Profile()))
 
 	if log.GetLevel() == log.DebugLevel {
-		debugJSON, _ := json.MarshalIndent(usr, "", "\t")
+		debugJSON, _ := json.MarshalIndent(usr.Account(), "", "\t")
 		log.Debug(msgs.DebugJSON, "usr", string(debugJSON))
 	}
 
-	ok := usr.IsTaken()
+	// hashed before the query timeout starts, it's slow on purpose
+	hash, ok := hashPassword(c, usr.Password)
 	if !ok {
+		return
+	}
+	usr.Password = hash
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	if !nameAvailable(ctx, users, usr.Name) {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrTaken,
 			"username is already taken",
@@ -55,25 +64,16 @@ func NewUser(c *gin.Context, users *mongo.Collection) {
 		return
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(usr.Password), bcrypt.MinCost)
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrEncryption,
-			"password exceeded the length of 72",
-		))
+	if !validAvatar(c, library, &usr, primitive.NilObjectID) {
 		return
 	}
-	usr.Password = string(hash)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
-
-	result, optionsErr := users.InsertOne(ctx, usr)
-	if optionsErr != nil {
+	id, err := users.Create(ctx, usr)
+	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrBadOptions,
-			"Bad options provided in the InsertOne",
-			optionsErr,
+			"failed creating the user",
+			"NewUser", err,
 		))
 		return
 	}
@@ -84,58 +84,73 @@ func NewUser(c *gin.Context, users *mongo.Collection) {
 	}{
 		Code:   http.StatusCreated,
 		Status: "OK",
-		ID:     result.InsertedID.(primitive.ObjectID).String(),
+		ID:     id.String(),
 	})
 }
 
-func GetUsers(c *gin.Context, usersColl *mongo.Collection) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
-
-	cursor, err := usersColl.Find(ctx, bson.M{})
+func GetUsers(c *gin.Context, usersStore storage.UserStore) {
+	p, err := pageParams(c, sortNew)
 	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"Bad options provided for Find",
-			"reason", "bad options provided for GetUsers",
-		))
 		return
 	}
 
-	var users []types.User
-	err = cursor.All(ctx, &users)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	users, err := usersStore.List(ctx, p.query())
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
-			"Failed decoding cursor",
-			"GetUsers cursor", err,
+			"failed listing users",
+			"GetUsers", err,
 		))
 		return
 	}
-	log.Debug(msgs.DebugStruct, "users", fmt.Sprintf("%#v\n", users))
-	c.JSON(http.StatusOK, users)
+	resp := pageOf(c, p, users, func(u types.User) (float64, primitive.ObjectID) {
+		return 0, u.ID
+	})
+	resp.Data = userViews(c, resp.Data.([]types.User))
+	c.JSON(http.StatusOK, resp)
 }
 
-func GetUser(c *gin.Context, users *mongo.Collection) {
+// userView picks what the requester may see of users, they see their own
+// account and admins see every account, anyone else only gets profiles.
+func userView(c *gin.Context) func(types.User) any {
+	value, ok := c.Get(requesterKey)
+	if !ok {
+		return func(u types.User) any { return u.Profile() }
+	}
+
+	viewer := value.(types.User)
+	admin := types.IsAdmin(viewer)
+	return func(u types.User) any {
+		if admin || u.ID == viewer.ID {
+			return u.Account()
+		}
+		return u.Profile()
+	}
+}
+
+func userViews(c *gin.Context, users []types.User) []any {
+	view := userView(c)
+	shown := make([]any, len(users))
+	for i, u := range users {
+		shown[i] = view(u)
+	}
+	return shown
+}
+
+func GetUser(c *gin.Context, users storage.UserStore) {
 	objid, err := idFromParams(c)
 	if err != nil {
 		return
 	}
 
-	filter := struct {
-		ID primitive.ObjectID `bson:"_id"`
-	}{
-		ID: objid,
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
 	defer cancel()
 
-	result := users.FindOne(ctx, filter)
-
-	var user types.User
-	err = result.Decode(&user)
-	if err == mongo.ErrNoDocuments {
+	user, err := users.Get(ctx, objid)
+	if err == storage.ErrNotFound {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrNotFound,
 			"user not found",
@@ -151,19 +166,31 @@ func GetUser(c *gin.Context, users *mongo.Collection) {
 		return
 	}
 
-	log.Debug(msgs.DebugStruct, "user", fmt.Sprintf("%#v\n", user))
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, userView(c)(user))
 }
 
-func UpdateUser(c *gin.Context, users *mongo.Collection) {
+func UpdateUser(c *gin.Context, users storage.UserStore, library *media.Library) {
 	objid, err := idFromParams(c)
 	if err != nil {
 		return
 	}
 
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	if objid != usr.ID {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrForbidden,
+			"action is forbidden!",
+			"UpdateUser", "ids aren't equal",
+		))
+		return
+	}
+
 	var bdy struct {
-		User      types.User        `json:"user"`
-		Requester types.Credentials `json:"requester"`
+		User types.User `json:"user"`
 	}
 
 	err = decodeBody(c, &bdy)
@@ -171,17 +198,19 @@ func UpdateUser(c *gin.Context, users *mongo.Collection) {
 		return
 	}
 
-	if err = bdy.Requester.Authorize(); err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotAuthorized,
-			"user wasn't authorized",
-			"error", err,
-		))
-		return
+	// an empty password keeps the current one instead of hashing nothing
+	if bdy.User.Password != "" {
+		hash, ok := hashPassword(c, bdy.User.Password)
+		if !ok {
+			return
+		}
+		bdy.User.Password = hash
 	}
 
-	var oldUsr types.User
-	err = getAndConvert(users, objid, &oldUsr)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	oldUsr, err := users.Get(ctx, objid)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
@@ -191,7 +220,7 @@ func UpdateUser(c *gin.Context, users *mongo.Collection) {
 	}
 
 	if oldUsr.Name != bdy.User.Name {
-		if ok := bdy.User.IsTaken(); !ok {
+		if !nameAvailable(ctx, users, bdy.User.Name) {
 			c.AbortWithStatusJSON(msgs.ReportError(
 				msgs.ErrTaken,
 				"username is taken",
@@ -200,41 +229,24 @@ func UpdateUser(c *gin.Context, users *mongo.Collection) {
 		}
 	}
 
-	usr, err := bdy.Requester.ToUser()
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"internal error",
-		))
+	if !validAvatar(c, library, &bdy.User, usr.ID) {
 		return
 	}
 
-	if objid != usr.ID {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrForbidden,
-			"action is forbidden!",
-			"UpdateUser", "ids aren't equal",
-		))
-		return
+	if bdy.User.Password == "" {
+		bdy.User.Password = oldUsr.Password
 	}
+	bdy.User.Muted = oldUsr.Muted
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
-
-	hash, err := bcrypt.GenerateFromPassword([]byte(bdy.User.Password), bcrypt.MinCost)
-	if err != nil {
+	err = users.Update(ctx, objid, bdy.User)
+	if err == storage.ErrNotFound || err == storage.ErrNotModified {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrEncryption,
-			"password exceeded the length of 72",
+			msgs.ErrUpdateFailed,
+			"failed to find the user",
+			"UpdateUser", err,
 		))
 		return
-	}
-	bdy.User.Password = string(hash)
-
-	update := bson.M{"$set": bdy.User}
-
-	updateResult, err := users.UpdateByID(ctx, objid, update)
-	if err != nil {
+	} else if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrBadOptions,
 			"id taken",
@@ -243,15 +255,6 @@ func UpdateUser(c *gin.Context, users *mongo.Collection) {
 		return
 	}
 
-	if updateResult.ModifiedCount == 0 {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrUpdateFailed,
-			"failed to find the user",
-			"UpdateUser", updateResult,
-		))
-		return
-	}
-
 	c.JSON(http.StatusAccepted, struct {
 		Code   int    `json:"code"`
 		Status string `json:"status"`
@@ -261,35 +264,148 @@ func UpdateUser(c *gin.Context, users *mongo.Collection) {
 	})
 }
 
-func DeleteUser(c *gin.Context, users *mongo.Collection) {
+// PatchUser changes the fields of the requester's account in the merge
+// patch body and answers with the account.
+func PatchUser(c *gin.Context, users storage.UserStore, library *media.Library) {
 	objid, err := idFromParams(c)
 	if err != nil {
 		return
 	}
 
-	body := struct {
-		Requester types.Credentials `json:"requester"`
-	}{}
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	if objid != usr.ID {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrForbidden,
+			"action is forbidden!",
+			"PatchUser", "ids aren't equal",
+		))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
 
-	err = decodeBody(c, &body)
+	current, err := users.Get(ctx, objid)
 	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"user not found",
+			"PatchUser", err,
+		))
+		return
+	}
+
+	patched := current
+	var password string
+	touched, ok := mergePatch(c, patchable{
+		"name":     &patched.Name,
+		"bio":      &patched.Bio,
+		"avatar":   &patched.Avatar,
+		"pronouns": &patched.Pronouns,
+		"email":    &patched.Email,
+		"password": &password,
+	})
+	if !ok {
 		return
 	}
 
-	if err := body.Requester.Authorize(); err != nil {
+	if patched.Name != current.Name {
+		if patched.Name == "" || !nameAvailable(ctx, users, patched.Name) {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrTaken,
+				"username is taken",
+			))
+			return
+		}
+	}
+
+	if patched.Email != current.Email {
+		if _, err := mail.ParseAddress(patched.Email); err != nil {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrWrongEmailFormat,
+				"email not formated properly",
+			))
+			return
+		}
+	}
+
+	if patched.Avatar != current.Avatar && !validAvatar(c, library, &patched, usr.ID) {
+		return
+	}
+
+	if slices.Contains(touched, "password") {
+		if password == "" {
+			c.AbortWithStatusJSON(msgs.ReportError(
+				msgs.ErrWrongFormat,
+				"password can't be empty",
+			))
+			return
+		}
+		hash, ok := hashPassword(c, password)
+		if !ok {
+			return
+		}
+		patched.Password = hash
+
+		// hashing may have used up the timeout of the reads
+		ctx, cancel = context.WithTimeout(context.Background(), timeouts.Query)
+		defer cancel()
+	}
+
+	err = users.Update(ctx, objid, patched)
+	if err != nil && err != storage.ErrNotModified {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotAuthorized,
-			"user not authorized",
+			msgs.ErrUpdateFailed,
+			"failed updating the user",
+			"PatchUser", err,
 		))
 		return
 	}
+	if stored, err := users.Get(ctx, objid); err == nil {
+		patched = stored
+	}
+
+	c.JSON(http.StatusOK, patched.Account())
+}
 
-	usr, err := body.Requester.ToUser()
+func hashPassword(c *gin.Context, password string) (string, bool) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"internal error",
+			msgs.ErrEncryption,
+			"password exceeded the length of 72",
 		))
+		return "", false
+	}
+	return string(hash), true
+}
+
+// validAvatar points the avatar at stored media, no avatar at all is fine.
+func validAvatar(c *gin.Context, library *media.Library, usr *types.User, uploader primitive.ObjectID) bool {
+	if usr.Avatar == "" {
+		return true
+	}
+
+	avatar, ok := resolveImage(c, library, usr.Avatar, uploader)
+	if !ok {
+		return false
+	}
+	usr.Avatar = avatar.ID
+	return true
+}
+
+func DeleteUser(c *gin.Context, users storage.UserStore) {
+	objid, err := idFromParams(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
 		return
 	}
 
@@ -302,26 +418,22 @@ func DeleteUser(c *gin.Context, users *mongo.Collection) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Write)
 	defer cancel()
 
-	filter := bson.M{"_id": objid}
-
-	deleteResult, err := users.DeleteOne(ctx, filter)
-	if err != nil {
+	err = users.Delete(ctx, objid)
+	if err == storage.ErrNotFound {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrBadOptions,
-			"id taken",
+			msgs.ErrNotFound,
+			"user failed to delete",
 			"DeleteUser", err,
 		))
 		return
-	}
-
-	if deleteResult.DeletedCount != 1 {
+	} else if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrNotFound,
-			"user failed to delete",
-			"DeleteUser", deleteResult.DeletedCount != 1,
+			msgs.ErrInternal,
+			"failed deleting the user",
+			"DeleteUser", err,
 		))
 		return
 	}
@@ -334,19 +446,24 @@ func DeleteUser(c *gin.Context, users *mongo.Collection) {
 	})
 }
 
-func SearchUser(c *gin.Context, users *mongo.Collection) {
+func SearchUser(c *gin.Context, users storage.UserStore) {
+	query, err := searchParams(c, userSearchFields)
+	if err != nil {
+		return
+	}
+
 	var length int
-	for _, v := range c.Request.URL.Query() {
+	for _, v := range query {
 		length += len(v)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
 	defer cancel()
 
 	var wg sync.WaitGroup
 	ch := make(chan findResultUsers, length)
 
-	for k, s := range c.Request.URL.Query() {
+	for k, s := range query {
 		for _, v := range s {
 			wg.Add(1)
 			log.Debug("searchuser", k, v)
@@ -367,6 +484,7 @@ func SearchUser(c *gin.Context, users *mongo.Collection) {
 		values = append(values, v.users...)
 	}
 
+	values = unique(values, func(v types.User) primitive.ObjectID { return v.ID })
 	if len(values) == 0 {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrNotFound,
@@ -376,75 +494,19 @@ func SearchUser(c *gin.Context, users *mongo.Collection) {
 		return
 	}
 
-	c.JSON(http.StatusOK, values)
+	c.JSON(http.StatusOK, userViews(c, values))
 }
 
-func MostPopularUsers(c *gin.Context, users, posts, comments *mongo.Collection) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+func MostPopularUsers(c *gin.Context, users storage.UserStore) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
 	defer cancel()
 
-	lookupPostsStage := bson.D{
-		{Key: "$lookup", Value: bson.D{
-			{Key: "from", Value: "posts"},
-			{Key: "localField", Value: "_id"},
-			{Key: "foreignField", Value: "author"},
-			{Key: "as", Value: "posts"},
-		}},
-	}
-
-	lookupCommentsStage := bson.D{
-		{Key: "$lookup", Value: bson.D{
-			{Key: "from", Value: "comments"},
-			{Key: "localField", Value: "_id"},
-			{Key: "foreignField", Value: "author"},
-			{Key: "as", Value: "comments"},
-		}},
-	}
-
-	projectStage := bson.D{
-		{Key: "$project", Value: bson.D{
-			{Key: "_id", Value: 0},
-			{Key: "name", Value: 1},
-			{Key: "votes", Value: bson.D{
-				{Key: "$add", Value: bson.A{
-					bson.D{{Key: "$sum", Value: "$posts.votes"}},
-					bson.D{{Key: "$sum", Value: "$comments.votes"}},
-				}},
-			}},
-		}},
-	}
-
-	sortStage := bson.D{
-		{Key: "$sort", Value: bson.D{
-			{Key: "votes", Value: -1},
-		}},
-	}
-
-	limitStage := bson.D{
-		{Key: "$limit", Value: 15},
-	}
-
-	pipeline := mongo.Pipeline{lookupPostsStage, lookupCommentsStage, projectStage, sortStage, limitStage}
-
-	cursor, err := users.Aggregate(ctx, pipeline)
-	if err != nil {
-		c.AbortWithStatusJSON(msgs.ReportError(
-			msgs.ErrInternal,
-			"internal error reading from cursor",
-		))
-		return
-	}
-
-	var answers []struct {
-		Name  string `json:"name"`
-		Votes int    `json:"votes"`
-	}
-
-	err = cursor.All(ctx, &answers)
+	answers, err := users.Popular(ctx, 15)
 	if err != nil {
 		c.AbortWithStatusJSON(msgs.ReportError(
 			msgs.ErrInternal,
 			"internal server error",
+			"MostPopularUsers", err,
 		))
 		return
 	}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"redoot/internal/live"
+	"redoot/internal/msgs"
+	"redoot/internal/storage"
+	"redoot/internal/types"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type voteResponse struct {
+	Code   int    `json:"code"`
+	Status string `json:"status"`
+	Votes  int    `json:"votes"`
+	MyVote int    `json:"myVote"`
+}
+
+// myVotes returns the votes of the requester on the targets, anonymous
+// requests get an empty map.
+func myVotes(c *gin.Context, votes storage.VoteStore, targets []primitive.ObjectID) map[primitive.ObjectID]int {
+	value, ok := c.Get(requesterKey)
+	if !ok || len(targets) == 0 {
+		return map[primitive.ObjectID]int{}
+	}
+	usr := value.(types.User)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	result, err := votes.Of(ctx, usr.ID, targets)
+	if err != nil {
+		log.Debug("failed getting votes", "user", usr.ID, "error", err)
+		return map[primitive.ObjectID]int{}
+	}
+	return result
+}
+
+func voteDirection(c *gin.Context) (int, error) {
+	if c.Request.Method == http.MethodDelete {
+		return 0, nil
+	}
+
+	var body struct {
+		Direction int `json:"direction"`
+	}
+	err := decodeBody(c, &body)
+	if err != nil {
+		return 0, err
+	}
+
+	if body.Direction != 1 && body.Direction != -1 {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrWrongFormat,
+			"direction must be either 1 or -1",
+		))
+		return 0, msgs.ErrWrongFormat
+	}
+	return body.Direction, nil
+}
+
+func VotePost(c *gin.Context, posts storage.PostStore, votes storage.VoteStore, events live.Publisher) {
+	boardId, postId, err := postId(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	direction, err := voteDirection(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	_, err = posts.Get(ctx, boardId, postId)
+	if err == storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"post not found!",
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"an internal error has accured",
+			"VotePost", err,
+		))
+		return
+	}
+
+	score, err := votes.Cast(ctx, usr.ID, postId, types.VotePost, direction)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed to vote",
+			"VotePost", err,
+		))
+		return
+	}
+	events.Publish(live.New(live.PostVoted, liveTarget{ID: postId, Board: boardId, Votes: &score}, live.Board(boardId), live.Post(postId)))
+
+	c.JSON(http.StatusOK, voteResponse{
+		Code:   http.StatusOK,
+		Status: "OK",
+		Votes:  score,
+		MyVote: direction,
+	})
+}
+
+func VoteComment(c *gin.Context, posts storage.PostStore, comments storage.CommentStore, votes storage.VoteStore, events live.Publisher) {
+	boardId, postId, commentId, err := commentIdParams(c)
+	if err != nil {
+		return
+	}
+
+	usr, err := requester(c)
+	if err != nil {
+		return
+	}
+
+	direction, err := voteDirection(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Query)
+	defer cancel()
+
+	_, err = posts.Get(ctx, boardId, postId)
+	if err == storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"post not found!",
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"an internal error has accured",
+			"VoteComment", err,
+		))
+		return
+	}
+
+	_, err = comments.Get(ctx, postId, commentId)
+	if err == storage.ErrNotFound {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrNotFound,
+			"comment not found",
+		))
+		return
+	} else if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"an internal error has accured",
+			"VoteComment", err,
+		))
+		return
+	}
+
+	score, err := votes.Cast(ctx, usr.ID, commentId, types.VoteComment, direction)
+	if err != nil {
+		c.AbortWithStatusJSON(msgs.ReportError(
+			msgs.ErrInternal,
+			"failed to vote",
+			"VoteComment", err,
+		))
+		return
+	}
+	events.Publish(live.New(live.CommentVoted, liveTarget{ID: commentId, Board: boardId, Post: &postId, Votes: &score}, live.Post(postId)))
+
+	c.JSON(http.StatusOK, voteResponse{
+		Code:   http.StatusOK,
+		Status: "OK",
+		Votes:  score,
+		MyVote: direction,
+	})
+}
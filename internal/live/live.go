@@ -0,0 +1,173 @@
+// Package live fans events out to the clients streaming a board, a post or
+// their notifications. Handlers publish to a Publisher, either the Hub of
+// this process or a Relay sharing the events with every replica.
+package live
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Event types.
+const (
+	PostCreated    = "post.created"
+	PostDeleted    = "post.deleted"
+	PostVoted      = "post.voted"
+	CommentCreated = "comment.created"
+	CommentDeleted = "comment.deleted"
+	CommentVoted   = "comment.voted"
+	Notification   = "notification"
+)
+
+// buffered is how many events a subscriber can fall behind before it's
+// dropped, its client reconnects and reloads what it missed.
+const buffered = 64
+
+type Event struct {
+	Type   string   `bson:"type"`
+	Topics []string `bson:"topics"`
+	// Data is the payload encoded as JSON once for every subscriber.
+	Data []byte    `bson:"data"`
+	At   time.Time `bson:"at"`
+}
+
+// New encodes the payload into an event for the topics.
+func New(kind string, data any, topics ...string) Event {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Error("failed encoding event", "type", kind, "error", err)
+		raw = []byte("null")
+	}
+	return Event{Type: kind, Topics: topics, Data: raw, At: time.Now()}
+}
+
+func Board(id primitive.ObjectID) string { return "board:" + id.Hex() }
+func Post(id primitive.ObjectID) string  { return "post:" + id.Hex() }
+func User(id primitive.ObjectID) string  { return "user:" + id.Hex() }
+
+// Publisher never blocks on slow subscribers and never fails the caller.
+type Publisher interface {
+	Publish(e Event)
+}
+
+// Subscription receives the events of its topics on C until it's closed.
+type Subscription struct {
+	C <-chan Event
+
+	hub    *Hub
+	topics []string
+	mu     sync.Mutex
+	ch     chan Event
+	closed bool
+}
+
+// Close stops the subscription and closes C, closing twice is fine.
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// send reports false when the subscriber fell too far behind.
+func (s *Subscription) send(e Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+
+	select {
+	case s.ch <- e:
+		return true
+	default:
+		return false
+	}
+}
+
+// Hub delivers events to the subscribers in this process.
+type Hub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{topics: map[string]map[*Subscription]struct{}{}}
+}
+
+func (h *Hub) Subscribe(topics ...string) *Subscription {
+	ch := make(chan Event, buffered)
+	s := &Subscription{C: ch, hub: h, topics: topics, ch: ch}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, topic := range topics {
+		if h.topics[topic] == nil {
+			h.topics[topic] = map[*Subscription]struct{}{}
+		}
+		h.topics[topic][s] = struct{}{}
+	}
+	return s
+}
+
+func (h *Hub) remove(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, topic := range s.topics {
+		delete(h.topics[topic], s)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+}
+
+// Publish hands the event to every subscriber of its topics once, even
+// when they subscribed to several of them.
+func (h *Hub) Publish(e Event) {
+	var lagging []*Subscription
+	delivered := map[*Subscription]bool{}
+
+	h.mu.RLock()
+	for _, topic := range e.Topics {
+		for s := range h.topics[topic] {
+			if delivered[s] {
+				continue
+			}
+			delivered[s] = true
+			if !s.send(e) {
+				lagging = append(lagging, s)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, s := range lagging {
+		log.Debug("dropping a lagging subscriber", "topics", s.topics)
+		s.Close()
+	}
+}
+
+// Close ends every subscription, the streams return and let the server
+// shut down.
+func (h *Hub) Close() {
+	h.mu.RLock()
+	all := map[*Subscription]bool{}
+	for _, subs := range h.topics {
+		for s := range subs {
+			all[s] = true
+		}
+	}
+	h.mu.RUnlock()
+
+	for s := range all {
+		s.Close()
+	}
+}
@@ -0,0 +1,88 @@
+package live
+
+import (
+	"context"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EventTTL is how long relayed events stay in the collection, long enough
+// for a replica to resume its change stream after a hiccup.
+const EventTTL = time.Hour
+
+// relayWrite bounds how long publishing waits on the database.
+const relayWrite = 5 * time.Second
+
+// Relay shares events between replicas through a collection: publishing
+// inserts the event and every replica, this one included, watches the
+// collection and hands what's inserted to its hub.
+type Relay struct {
+	events *mongo.Collection
+	hub    *Hub
+}
+
+func NewRelay(db *mongo.Database, hub *Hub) *Relay {
+	return &Relay{events: db.Collection("events"), hub: hub}
+}
+
+func (r *Relay) Publish(e Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), relayWrite)
+	defer cancel()
+
+	_, err := r.events.InsertOne(ctx, e)
+	if err != nil {
+		// the other replicas miss it but the clients of this one don't
+		log.Error("failed relaying event, publishing locally", "type", e.Type, "error", err)
+		r.hub.Publish(e)
+	}
+}
+
+// Run watches the collection until the context ends, resuming after errors
+// where it left off.
+func (r *Relay) Run(ctx context.Context) {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"operationType": "insert"}}}}
+	var resume bson.Raw
+
+	for ctx.Err() == nil {
+		opts := options.ChangeStream()
+		if resume != nil {
+			opts.SetResumeAfter(resume)
+		}
+
+		stream, err := r.events.Watch(ctx, pipeline, opts)
+		if err != nil {
+			log.Error("failed watching events", "error", err)
+			sleep(ctx, time.Second)
+			continue
+		}
+
+		for stream.Next(ctx) {
+			var change struct {
+				Event Event `bson:"fullDocument"`
+			}
+			if err := stream.Decode(&change); err != nil {
+				log.Error("failed decoding relayed event", "error", err)
+				continue
+			}
+			r.hub.Publish(change.Event)
+			resume = stream.ResumeToken()
+		}
+
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			log.Error("event stream broke", "error", err)
+			sleep(ctx, time.Second)
+		}
+		stream.Close(context.Background())
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
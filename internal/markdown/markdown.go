@@ -0,0 +1,562 @@
+// Package markdown renders the Markdown of post and comment bodies to HTML.
+//
+// Nothing from the source reaches the output unescaped. The renderer writes
+// the only tags there are itself: p, br, h1 to h6, blockquote, pre, code, ul,
+// ol, li, em, strong, del, a and span, the span only as class="spoiler".
+// Links keep their href only when it's a path on this site or uses one of
+// allowedSchemes. Raw HTML in the source shows up as text.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxMentions bounds how many distinct names a body can link, every one of
+// them costs a lookup.
+const MaxMentions = 20
+
+// maxDepth bounds the nesting of quotes and inline markup so hostile input
+// can't blow up the recursion, maxLabel how far a link's text can reach.
+const (
+	maxDepth = 8
+	maxLabel = 1000
+)
+
+const punctuation = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+var allowedSchemes = []string{"http", "https", "mailto"}
+
+// linkRel keeps user supplied links from passing on ranking or the opener.
+const linkRel = "nofollow noopener noreferrer ugc"
+
+var (
+	headingLine = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?[ \t]*#*[ \t]*$`)
+	bulletLine  = regexp.MustCompile(`^ {0,3}([-*+])[ \t]+(.*)$`)
+	orderedLine = regexp.MustCompile(`^ {0,3}(\d{1,9})[.)][ \t]+(.*)$`)
+	fenceLine   = regexp.MustCompile("^ {0,3}(```+|~~~+)[ \t]*([^`]*)$")
+	mention     = regexp.MustCompile(`^(?:/?([ub])/|@)([A-Za-z0-9_-]{2,64})`)
+	language    = regexp.MustCompile(`^[A-Za-z0-9_+#.-]{1,32}$`)
+)
+
+// Links maps mentioned names to the paths they link to, names missing from
+// it stay plain text.
+type Links struct {
+	Users  map[string]string
+	Boards map[string]string
+}
+
+// Render turns the Markdown into HTML with the mentions in links linked.
+func Render(src string, links Links) string {
+	r := &renderer{links: links}
+	r.blocks(lines(src), 0)
+	return r.out.String()
+}
+
+// Mentions lists the distinct user and board names the source mentions
+// outside of code, at most MaxMentions of them.
+func Mentions(src string) (users, boards []string) {
+	r := &renderer{seen: map[string]bool{}}
+	r.blocks(lines(src), 0)
+	return r.users, r.boards
+}
+
+type renderer struct {
+	out   strings.Builder
+	links Links
+
+	// collecting mentions instead of resolving them
+	seen   map[string]bool
+	users  []string
+	boards []string
+}
+
+func lines(src string) []string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	src = strings.ReplaceAll(src, "\x00", "�")
+	return strings.Split(src, "\n")
+}
+
+func blank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// quoteLine strips the marker of a block quote line, >! starts a spoiler
+// instead.
+func quoteLine(line string) (string, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || !strings.HasPrefix(trimmed, ">") || strings.HasPrefix(trimmed, ">!") {
+		return "", false
+	}
+	trimmed = trimmed[1:]
+	if strings.HasPrefix(trimmed, " ") {
+		trimmed = trimmed[1:]
+	}
+	return trimmed, true
+}
+
+// startsBlock tells whether the line interrupts a paragraph.
+func startsBlock(line string) bool {
+	if _, ok := quoteLine(line); ok {
+		return true
+	}
+	return fenceLine.MatchString(line) || headingLine.MatchString(line) ||
+		bulletLine.MatchString(line) || orderedLine.MatchString(line)
+}
+
+func (r *renderer) blocks(lines []string, depth int) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case blank(line):
+			i++
+
+		case fenceLine.MatchString(line):
+			i = r.fence(lines, i)
+
+		case headingLine.MatchString(line):
+			m := headingLine.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			r.out.WriteString("<h" + level + ">")
+			r.inline(m[2], depth, false)
+			r.out.WriteString("</h" + level + ">\n")
+			i++
+
+		case depth < maxDepth && isQuote(line):
+			var inner []string
+			for ; i < len(lines); i++ {
+				stripped, ok := quoteLine(lines[i])
+				if !ok {
+					break
+				}
+				inner = append(inner, stripped)
+			}
+			r.out.WriteString("<blockquote>\n")
+			r.blocks(inner, depth+1)
+			r.out.WriteString("</blockquote>\n")
+
+		case bulletLine.MatchString(line):
+			i = r.list(lines, i, bulletLine, depth)
+
+		case orderedLine.MatchString(line):
+			i = r.list(lines, i, orderedLine, depth)
+
+		default:
+			var para []string
+			for ; i < len(lines) && !blank(lines[i]); i++ {
+				if len(para) > 0 && startsBlock(lines[i]) {
+					break
+				}
+				para = append(para, strings.TrimSpace(lines[i]))
+			}
+			r.out.WriteString("<p>")
+			r.inline(strings.Join(para, "\n"), depth, false)
+			r.out.WriteString("</p>\n")
+		}
+	}
+}
+
+func isQuote(line string) bool {
+	_, ok := quoteLine(line)
+	return ok
+}
+
+// fence writes a fenced code block and returns the line after it, an
+// unclosed fence runs to the end.
+func (r *renderer) fence(lines []string, start int) int {
+	m := fenceLine.FindStringSubmatch(lines[start])
+	marker := m[1]
+	info := strings.Fields(m[2])
+
+	var code []string
+	i := start + 1
+	for ; i < len(lines); i++ {
+		closing := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(closing, marker) && strings.Trim(closing, marker[:1]) == "" {
+			i++
+			break
+		}
+		code = append(code, lines[i])
+	}
+
+	r.out.WriteString("<pre><code")
+	if len(info) > 0 && language.MatchString(info[0]) {
+		r.out.WriteString(` class="language-` + html.EscapeString(info[0]) + `"`)
+	}
+	r.out.WriteString(">")
+	for _, line := range code {
+		r.out.WriteString(html.EscapeString(line))
+		r.out.WriteString("\n")
+	}
+	r.out.WriteString("</code></pre>\n")
+	return i
+}
+
+// list writes consecutive items of the same kind, indented lines continue
+// the item above them.
+func (r *renderer) list(lines []string, start int, kind *regexp.Regexp, depth int) int {
+	tag := "ul"
+	if kind == orderedLine {
+		tag = "ol"
+	}
+
+	r.out.WriteString("<" + tag)
+	if first := kind.FindStringSubmatch(lines[start]); tag == "ol" && first[1] != "1" {
+		n, _ := strconv.Atoi(first[1])
+		r.out.WriteString(` start="` + strconv.Itoa(n) + `"`)
+	}
+	r.out.WriteString(">\n")
+
+	i := start
+	for i < len(lines) {
+		m := kind.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		item := []string{m[2]}
+		for i++; i < len(lines) && !blank(lines[i]) && strings.HasPrefix(lines[i], "  ") && !kind.MatchString(lines[i]); i++ {
+			item = append(item, strings.TrimSpace(lines[i]))
+		}
+
+		r.out.WriteString("<li>")
+		r.inline(strings.Join(item, "\n"), depth, false)
+		r.out.WriteString("</li>\n")
+
+		// a single blank line between items keeps the list going
+		if i+1 < len(lines) && blank(lines[i]) && kind.MatchString(lines[i+1]) {
+			i++
+		}
+	}
+
+	r.out.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// inline writes text with its inline markup, inLink keeps links from
+// nesting. Whether a closing delimiter is valid only depends on where it is,
+// so once one wasn't found it won't be for any later opener either and
+// unclosed remembers that to keep the scanning linear.
+func (r *renderer) inline(text string, depth int, inLink bool) {
+	unclosed := map[string]bool{}
+	i := 0
+	for i < len(text) {
+		c := text[i]
+		rest := text[i:]
+
+		switch {
+		case c == '\\' && i+1 < len(text) && isPunct(text[i+1]):
+			r.out.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '\n':
+			r.out.WriteString("\n")
+			i++
+			continue
+
+		case c == '`':
+			ticks := len(rest) - len(strings.TrimLeft(rest, "`"))
+			if !unclosed[rest[:ticks]] {
+				if n := r.codeSpan(rest); n > 0 {
+					i += n
+					continue
+				}
+				unclosed[rest[:ticks]] = true
+			}
+			r.out.WriteString(rest[:ticks])
+			i += ticks
+			continue
+
+		case depth < maxDepth && strings.HasPrefix(rest, ">!") && !unclosed[">!"]:
+			if end := strings.Index(rest[2:], "!<"); end > 0 {
+				r.out.WriteString(`<span class="spoiler">`)
+				r.inline(rest[2:2+end], depth+1, inLink)
+				r.out.WriteString("</span>")
+				i += end + 4
+				continue
+			} else if end < 0 {
+				unclosed[">!"] = true
+			}
+
+		case depth < maxDepth && c == '[' && !inLink:
+			if n := r.link(rest, depth); n > 0 {
+				i += n
+				continue
+			}
+
+		case depth < maxDepth && (c == '*' || c == '_' || c == '~'):
+			if n := r.emphasis(text, i, depth, inLink, unclosed); n > 0 {
+				i += n
+				continue
+			}
+
+		case !inLink && (c == 'h' || c == 'H') && wordStart(text, i):
+			if n := r.autolink(rest); n > 0 {
+				i += n
+				continue
+			}
+
+		case (c == 'u' || c == 'b' || c == '/' || c == '@') && wordStart(text, i):
+			if n := r.mention(rest, inLink); n > 0 {
+				i += n
+				continue
+			}
+		}
+
+		_, size := utf8.DecodeRuneInString(rest)
+		r.out.WriteString(html.EscapeString(rest[:size]))
+		i += size
+	}
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte(punctuation, c) >= 0
+}
+
+// wordStart tells whether position i doesn't continue a word or a path.
+func wordStart(text string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev, _ := utf8.DecodeLastRuneInString(text[:i])
+	return !(unicode.IsLetter(prev) || unicode.IsNumber(prev) || prev == '/' || prev == '_' || prev == '-')
+}
+
+// codeSpan writes `code` and returns how much it consumed, zero when the
+// backticks aren't closed.
+func (r *renderer) codeSpan(text string) int {
+	ticks := len(text) - len(strings.TrimLeft(text, "`"))
+	fence := text[:ticks]
+
+	for at := ticks; at < len(text); {
+		end := strings.Index(text[at:], fence)
+		if end < 0 {
+			return 0
+		}
+		end += at
+		after := end + ticks
+		if after < len(text) && text[after] == '`' {
+			at = after + len(text[after:]) - len(strings.TrimLeft(text[after:], "`"))
+			continue
+		}
+
+		code := strings.ReplaceAll(text[ticks:end], "\n", " ")
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+			code = code[1 : len(code)-1]
+		}
+		r.out.WriteString("<code>" + html.EscapeString(code) + "</code>")
+		return after
+	}
+	return 0
+}
+
+// link writes [text](url) and returns how much it consumed. Links with a
+// scheme that isn't allowed keep only their text.
+func (r *renderer) link(text string, depth int) int {
+	closing := matchingBracket(text[:min(len(text), maxLabel)])
+	if closing < 0 || closing+1 >= len(text) || text[closing+1] != '(' {
+		return 0
+	}
+	end := closingParen(text[closing+2 : min(len(text), closing+2+maxLabel)])
+	if end < 0 {
+		return 0
+	}
+	end += closing + 2
+
+	target := text[closing+2 : end]
+	label := text[1:closing]
+
+	if href, ok := safeURL(target); ok {
+		r.out.WriteString(`<a href="` + html.EscapeString(href) + `" rel="` + linkRel + `">`)
+		r.inline(label, depth+1, true)
+		r.out.WriteString("</a>")
+	} else {
+		r.inline(label, depth+1, true)
+	}
+	return end + 1
+}
+
+// closingParen finds the end of a link target, parentheses inside it have to
+// be balanced and whitespace ends it early.
+func closingParen(text string) int {
+	level := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case ' ', '\t', '\n':
+			return -1
+		case '(':
+			level++
+		case ')':
+			if level == 0 {
+				return i
+			}
+			level--
+		}
+	}
+	return -1
+}
+
+func matchingBracket(text string) int {
+	level := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '[':
+			level++
+		case ']':
+			level--
+			if level == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// safeURL only lets through absolute URLs with an allowed scheme and paths
+// on this site.
+func safeURL(raw string) (string, bool) {
+	for _, r := range raw {
+		if unicode.IsControl(r) {
+			return "", false
+		}
+	}
+
+	if strings.HasPrefix(raw, "/") && !strings.HasPrefix(raw, "//") && !strings.HasPrefix(raw, "/\\") {
+		return raw, true
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return "", false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	for _, allowed := range allowedSchemes {
+		if scheme == allowed && (scheme == "mailto" || u.Host != "") {
+			return u.String(), true
+		}
+	}
+	return "", false
+}
+
+// emphasis writes **strong**, *em*, _em_ or ~~del~~ starting at i and
+// returns how much it consumed.
+func (r *renderer) emphasis(text string, i, depth int, inLink bool, unclosed map[string]bool) int {
+	c := text[i]
+	delim := text[i : i+1]
+	if i+1 < len(text) && text[i+1] == c {
+		delim = text[i : i+2]
+	}
+	if c == '~' && len(delim) != 2 || unclosed[delim] {
+		return 0
+	}
+	// snake_case stays as it is
+	if c == '_' && !wordStart(text, i) {
+		return 0
+	}
+
+	start := i + len(delim)
+	if start >= len(text) || unicode.IsSpace(rune(text[start])) {
+		return 0
+	}
+
+	for at := start; at < len(text); {
+		end := strings.Index(text[at:], delim)
+		if end < 0 {
+			unclosed[delim] = true
+			return 0
+		}
+		end += at
+		after := end + len(delim)
+		if end > start && !unicode.IsSpace(rune(text[end-1])) && (after >= len(text) || text[after] != c && (c != '_' || wordStart(text, after+1))) {
+			tag := "em"
+			switch {
+			case c == '~':
+				tag = "del"
+			case len(delim) == 2:
+				tag = "strong"
+			}
+			r.out.WriteString("<" + tag + ">")
+			r.inline(text[start:end], depth+1, inLink)
+			r.out.WriteString("</" + tag + ">")
+			return after - i
+		}
+		at = end + 1
+	}
+	unclosed[delim] = true
+	return 0
+}
+
+// autolink links a bare http or https URL, trailing punctuation belongs to
+// the sentence.
+func (r *renderer) autolink(text string) int {
+	lower := strings.ToLower(text[:min(len(text), 8)])
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+		return 0
+	}
+
+	end := strings.IndexFunc(text, func(r rune) bool { return unicode.IsSpace(r) || r == '<' })
+	if end < 0 {
+		end = len(text)
+	}
+	raw := strings.TrimRight(text[:end], ".,:;!?'\"")
+	if strings.HasSuffix(raw, ")") && !strings.Contains(raw, "(") {
+		raw = strings.TrimRight(raw, ")")
+	}
+
+	href, ok := safeURL(raw)
+	if !ok {
+		return 0
+	}
+	r.out.WriteString(`<a href="` + html.EscapeString(href) + `" rel="` + linkRel + `">` + html.EscapeString(raw) + "</a>")
+	return len(raw)
+}
+
+// mention links u/name, @name and b/name when links knows them, or records
+// them when collecting.
+func (r *renderer) mention(text string, inLink bool) int {
+	m := mention.FindStringSubmatch(text)
+	if m == nil {
+		return 0
+	}
+	// the name has to end there, u/name/more is a path
+	if next := len(m[0]); next < len(text) && (text[next] == '/' || text[next] == '.' && next+1 < len(text) && !unicode.IsSpace(rune(text[next+1]))) {
+		return 0
+	}
+	kind, name := m[1], m[2]
+	if kind == "" {
+		kind = "u"
+	}
+
+	if r.seen != nil {
+		key := kind + "/" + name
+		if !r.seen[key] && len(r.seen) < MaxMentions {
+			r.seen[key] = true
+			if kind == "u" {
+				r.users = append(r.users, name)
+			} else {
+				r.boards = append(r.boards, name)
+			}
+		}
+		return 0
+	}
+
+	targets := r.links.Users
+	if kind == "b" {
+		targets = r.links.Boards
+	}
+	href, ok := targets[name]
+	if !ok || inLink {
+		return 0
+	}
+
+	r.out.WriteString(`<a href="` + html.EscapeString(href) + `" class="mention">` + html.EscapeString(m[0]) + "</a>")
+	return len(m[0])
+}
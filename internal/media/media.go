@@ -0,0 +1,219 @@
+// Package media stores uploaded images by the hash of their content next to
+// a thumbnail, avatars and image posts point at them by id.
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"redoot/internal/content"
+	"redoot/internal/storage"
+	"redoot/internal/types"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ThumbSize bounds both sides of a thumbnail, smaller images are their own
+// thumbnail.
+const ThumbSize = 320
+
+const thumbSuffix = "-thumb"
+
+var (
+	ErrType    = errors.New("media must be a png, jpeg or gif image")
+	ErrUnknown = errors.New("image must be the id of uploaded media or a base64 encoded image")
+)
+
+// sniffed are the content types uploads can have with the format the image
+// decoder has to agree on.
+var sniffed = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpeg",
+	"image/gif":  "gif",
+}
+
+var mediaId = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// IsID tells whether s has the shape of a media id.
+func IsID(s string) bool {
+	return mediaId.MatchString(s)
+}
+
+// ThumbID is the id the thumbnail of the media is stored under.
+func ThumbID(id string) string {
+	return id + thumbSuffix
+}
+
+type Library struct {
+	blobs storage.BlobStore
+}
+
+func New(blobs storage.BlobStore) *Library {
+	return &Library{blobs}
+}
+
+// Save checks the upload is an image within the limits and stores it with a
+// thumbnail. Uploading the same bytes again returns the stored media.
+func (l *Library) Save(ctx context.Context, data []byte, uploader primitive.ObjectID) (types.Media, error) {
+	if len(data) > content.MaxImageBytes {
+		return types.Media{}, content.ErrImageSize
+	}
+
+	contentType := http.DetectContentType(data)
+	format, ok := sniffed[contentType]
+	if !ok {
+		return types.Media{}, ErrType
+	}
+
+	info, err := content.ImageInfo(data)
+	if err != nil {
+		return types.Media{}, err
+	}
+	if info.Format != format {
+		return types.Media{}, ErrType
+	}
+
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:])
+
+	existing, err := l.blobs.Stat(ctx, id)
+	if err == nil {
+		return existing, nil
+	} else if err != storage.ErrNotFound {
+		return types.Media{}, err
+	}
+
+	media := types.Media{
+		ID:          id,
+		ContentType: contentType,
+		ImageInfo:   info,
+		Uploader:    uploader,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	thumb, thumbData, err := thumbnail(media, data)
+	if err != nil {
+		return types.Media{}, err
+	}
+
+	// the thumbnail goes first so any stored media has one
+	err = l.blobs.Put(ctx, thumb, thumbData)
+	if err != nil {
+		return types.Media{}, err
+	}
+	err = l.blobs.Put(ctx, media, data)
+	if err != nil {
+		return types.Media{}, err
+	}
+	return media, nil
+}
+
+// Open reads the media or its thumbnail.
+func (l *Library) Open(ctx context.Context, id string, thumb bool) (types.Media, []byte, error) {
+	if !IsID(id) {
+		return types.Media{}, nil, storage.ErrNotFound
+	}
+	if thumb {
+		id = ThumbID(id)
+	}
+	return l.blobs.Get(ctx, id)
+}
+
+// Resolve turns a reference to an image into stored media, the reference is
+// either a media id or an image in base64 which is saved first.
+func (l *Library) Resolve(ctx context.Context, ref string, uploader primitive.ObjectID) (types.Media, error) {
+	if IsID(ref) {
+		media, err := l.blobs.Stat(ctx, ref)
+		if err == storage.ErrNotFound {
+			return types.Media{}, ErrUnknown
+		}
+		return media, err
+	}
+
+	data, _, err := content.Image(ref)
+	if err == content.ErrImage {
+		return types.Media{}, ErrUnknown
+	} else if err != nil {
+		return types.Media{}, err
+	}
+	return l.Save(ctx, data, uploader)
+}
+
+// thumbnail scales the image down to fit ThumbSize, jpegs stay jpegs and
+// everything else becomes a png so transparency survives.
+func thumbnail(media types.Media, data []byte) (types.Media, []byte, error) {
+	thumb := media
+	thumb.ID = ThumbID(media.ID)
+
+	if media.Width <= ThumbSize && media.Height <= ThumbSize {
+		return thumb, data, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return types.Media{}, nil, content.ErrImage
+	}
+
+	width, height := ThumbSize, ThumbSize
+	if media.Width > media.Height {
+		height = max(1, media.Height*ThumbSize/media.Width)
+	} else {
+		width = max(1, media.Width*ThumbSize/media.Height)
+	}
+	dst := scale(src, width, height)
+
+	var buf bytes.Buffer
+	if media.Format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	} else {
+		thumb.ContentType, thumb.Format = "image/png", "png"
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return types.Media{}, nil, err
+	}
+
+	thumb.Width, thumb.Height, thumb.Size = width, height, buf.Len()
+	return thumb, buf.Bytes(), nil
+}
+
+// scale shrinks src by averaging the block of source pixels behind every
+// destination pixel.
+func scale(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := max(y0+1, b.Min.Y+(y+1)*b.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := max(x0+1, b.Min.X+(x+1)*b.Dx()/width)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
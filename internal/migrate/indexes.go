@@ -0,0 +1,144 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"redoot/internal/live"
+	"redoot/internal/types"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// textIndex weights have to match the fields the memory store scores.
+func textIndex(weights bson.D) mongo.IndexModel {
+	keys := bson.D{}
+	for _, w := range weights {
+		keys = append(keys, bson.E{Key: w.Key, Value: "text"})
+	}
+	return mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetWeights(weights).SetName("text"),
+	}
+}
+
+// baseIndexes are the indexes the server created on every start before it
+// had migrations.
+var baseIndexes = map[string][]mongo.IndexModel{
+	"users": {
+		textIndex(bson.D{{Key: "name", Value: 10}, {Key: "bio", Value: 1}}),
+	},
+	"votes": {
+		// one vote per user, concurrent first votes can't both insert
+		{
+			Keys:    bson.D{{Key: "user", Value: 1}, {Key: "target", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	},
+	"boards": {
+		{Keys: bson.D{{Key: "deletedAt", Value: 1}}},
+		textIndex(bson.D{{Key: "name", Value: 10}, {Key: "bio", Value: 2}, {Key: "rules", Value: 1}}),
+	},
+	"posts": {
+		{Keys: bson.D{{Key: "deletedAt", Value: 1}}},
+		{Keys: bson.D{{Key: "deletedWith", Value: 1}}},
+		{Keys: bson.D{{Key: "board", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "board", Value: 1}, {Key: "votes", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "board", Value: 1}, {Key: "controversy", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "votes", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "controversy", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "board", Value: 1}, {Key: "hot", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "hot", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "board", Value: 1}, {Key: "rising", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "rising", Value: -1}, {Key: "_id", Value: -1}}},
+		textIndex(bson.D{{Key: "title", Value: 10}, {Key: "bodyContent", Value: 1}}),
+		{
+			Keys:    bson.D{{Key: "board", Value: 1}, {Key: "bodyContent", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"bodyType": types.Link}),
+		},
+	},
+	"comments": {
+		{Keys: bson.D{{Key: "deletedAt", Value: 1}}},
+		{Keys: bson.D{{Key: "deletedWith", Value: 1}}},
+		{Keys: bson.D{{Key: "post", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "post", Value: 1}, {Key: "votes", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "post", Value: 1}, {Key: "controversy", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "parent", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "parent", Value: 1}, {Key: "votes", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "parent", Value: 1}, {Key: "controversy", Value: -1}, {Key: "_id", Value: -1}}},
+		textIndex(bson.D{{Key: "body", Value: 1}}),
+	},
+	"events": {
+		{
+			Keys:    bson.D{{Key: "at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(live.EventTTL.Seconds())),
+		},
+	},
+	"notifications": {
+		{Keys: bson.D{{Key: "user", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user", Value: 1}, {Key: "read", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "post", Value: 1}}},
+		{Keys: bson.D{{Key: "board", Value: 1}}},
+	},
+	"subscriptions": {
+		{
+			Keys:    bson.D{{Key: "user", Value: 1}, {Key: "board", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "board", Value: 1}}},
+	},
+	"revisions": {
+		{Keys: bson.D{{Key: "post", Value: 1}, {Key: "comment", Value: 1}, {Key: "_id", Value: -1}}},
+	},
+}
+
+func createIndexes(ctx context.Context, db *mongo.Database, indexes map[string][]mongo.IndexModel) error {
+	for coll, models := range indexes {
+		_, err := db.Collection(coll).Indexes().CreateMany(ctx, models)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropIndexes drops the indexes by the names mongo gave them, ones already
+// gone are fine.
+func dropIndexes(ctx context.Context, db *mongo.Database, indexes map[string][]mongo.IndexModel) error {
+	for coll, models := range indexes {
+		for _, model := range models {
+			_, err := db.Collection(coll).Indexes().DropOne(ctx, indexName(model))
+			if err != nil && !missing(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// indexName is the name set in the options or the default mongo derives
+// from the keys, like board_1__id_-1.
+func indexName(model mongo.IndexModel) string {
+	if model.Options != nil && model.Options.Name != nil {
+		return *model.Options.Name
+	}
+
+	parts := []string{}
+	for _, key := range model.Keys.(bson.D) {
+		parts = append(parts, fmt.Sprintf("%s_%v", key.Key, key.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// missing reports the errors of dropping an index or a collection that
+// isn't there.
+func missing(err error) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Code == 26 || cmdErr.Code == 27
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+// Package migrate versions the mongo schema. Indexes, backfills and field
+// renames are Go steps applied in order, each applied version is recorded in
+// the schema_migrations collection and a lock keeps replicas starting at the
+// same time from running them twice.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrNothingApplied = errors.New("no migration is applied")
+	ErrUnknown        = errors.New("the latest applied migration is unknown to this build")
+)
+
+// Migration changes the schema from the version before it to Version, Down
+// undoes what Up did.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+	Down        func(ctx context.Context, db *mongo.Database) error
+}
+
+// State is a migration and when it was applied, nil while it's pending.
+type State struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type applied struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+}
+
+func history(db *mongo.Database) *mongo.Collection {
+	return db.Collection("schema_migrations")
+}
+
+func appliedVersions(ctx context.Context, db *mongo.Database) (map[int]applied, error) {
+	cursor, err := history(db).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []applied
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	versions := map[int]applied{}
+	for _, record := range records {
+		versions[record.Version] = record
+	}
+	return versions, nil
+}
+
+// Status lists every migration of this build oldest first.
+func Status(ctx context.Context, db *mongo.Database) ([]State, error) {
+	versions, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]State, len(migrations))
+	for i, m := range migrations {
+		states[i] = State{Migration: m}
+		if record, ok := versions[m.Version]; ok {
+			states[i].AppliedAt = &record.AppliedAt
+		}
+	}
+	return states, nil
+}
+
+// Up applies the pending migrations in order and returns them, it stops at
+// the first one failing.
+func Up(ctx context.Context, db *mongo.Database) ([]Migration, error) {
+	done := []Migration{}
+	err := withLock(ctx, db, func() error {
+		versions, err := appliedVersions(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := versions[m.Version]; ok {
+				continue
+			}
+
+			log.Info("applying migration", "version", m.Version, "description", m.Description)
+			if err := m.Up(ctx, db); err != nil {
+				return fmt.Errorf("migration %d: %w", m.Version, err)
+			}
+
+			_, err := history(db).InsertOne(ctx, applied{
+				Version:     m.Version,
+				Description: m.Description,
+				AppliedAt:   time.Now().UTC(),
+			})
+			if err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the latest applied migration and returns it.
+func Down(ctx context.Context, db *mongo.Database) (Migration, error) {
+	var reverted Migration
+	err := withLock(ctx, db, func() error {
+		var latest applied
+		opts := options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})
+		err := history(db).FindOne(ctx, bson.M{}, opts).Decode(&latest)
+		if err == mongo.ErrNoDocuments {
+			return ErrNothingApplied
+		} else if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if m.Version != latest.Version {
+				continue
+			}
+
+			log.Info("reverting migration", "version", m.Version, "description", m.Description)
+			if err := m.Down(ctx, db); err != nil {
+				return fmt.Errorf("migration %d: %w", m.Version, err)
+			}
+
+			_, err := history(db).DeleteOne(ctx, bson.M{"_id": m.Version})
+			reverted = m
+			return err
+		}
+		return ErrUnknown
+	})
+	return reverted, err
+}
+
+// lockLease is how long a lock outlives a replica that died holding it, the
+// holder refreshes it every lockRefresh.
+const (
+	lockLease   = time.Minute
+	lockRefresh = lockLease / 3
+	lockPoll    = time.Second
+)
+
+const lockId = "migrations"
+
+// withLock runs fn while holding the migration lock, waiting for another
+// replica holding it until the context ends.
+func withLock(ctx context.Context, db *mongo.Database, fn func() error) error {
+	locks := db.Collection("schema_lock")
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s/%d/%s", host, os.Getpid(), primitive.NewObjectID().Hex())
+
+	if err := acquire(ctx, locks, owner); err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), lockPoll)
+		defer cancel()
+		_, err := locks.DeleteOne(ctx, bson.M{"_id": lockId, "owner": owner})
+		if err != nil {
+			log.Error("failed releasing the migration lock, it expires on its own", "error", err)
+		}
+	}()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go refresh(locks, owner, stop)
+
+	return fn()
+}
+
+// acquire takes the lock when it's free or its lease ran out, the unique _id
+// makes the upsert of everyone else fail.
+func acquire(ctx context.Context, locks *mongo.Collection, owner string) error {
+	waiting := false
+	for {
+		now := time.Now().UTC()
+		filter := bson.M{"_id": lockId, "$or": bson.A{
+			bson.M{"owner": owner},
+			bson.M{"expiresAt": bson.M{"$lt": now}},
+		}}
+		update := bson.M{"$set": bson.M{"owner": owner, "expiresAt": now.Add(lockLease)}}
+
+		_, err := locks.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+
+		if !waiting {
+			log.Info("waiting for another replica to finish migrating")
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+}
+
+func refresh(locks *mongo.Collection, owner string, stop <-chan struct{}) {
+	ticker := time.NewTicker(lockRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), lockPoll)
+			_, err := locks.UpdateOne(ctx,
+				bson.M{"_id": lockId, "owner": owner},
+				bson.M{"$set": bson.M{"expiresAt": time.Now().UTC().Add(lockLease)}},
+			)
+			cancel()
+			if err != nil {
+				log.Error("failed refreshing the migration lock", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package migrate
+
+import (
+	"context"
+	"redoot/internal/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrations in the order they are applied, versions only ever grow and an
+// applied migration is never changed, a new one fixes it instead.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create the indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndexes(ctx, db, baseIndexes)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db, baseIndexes)
+		},
+	},
+	{
+		Version:     2,
+		Description: "backfill post ranks",
+		Up:          storage.BackfillRanks,
+		Down:        derived,
+	},
+	{
+		Version:     3,
+		Description: "backfill timestamps from ids",
+		Up:          storage.BackfillTimestamps,
+		Down:        derived,
+	},
+	{
+		Version:     4,
+		Description: "unique user names",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndexes(ctx, db, uniqueNames)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db, uniqueNames)
+		},
+	},
+	{
+		Version:     5,
+		Description: "unique roles",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := dedupeRoles(ctx, db); err != nil {
+				return err
+			}
+			return createIndexes(ctx, db, uniqueRoles)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db, uniqueRoles)
+		},
+	},
+}
+
+var uniqueNames = map[string][]mongo.IndexModel{
+	"users": {
+		{
+			Keys:    bson.D{{Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	},
+}
+
+var uniqueRoles = map[string][]mongo.IndexModel{
+	"roles": {
+		{
+			Keys:    bson.D{{Key: "user", Value: 1}, {Key: "role", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	},
+}
+
+// dedupeRoles keeps the earliest grant of roles racing grants stored twice,
+// the unique index can't be built over them.
+func dedupeRoles(ctx context.Context, db *mongo.Database) error {
+	roles := db.Collection("roles")
+	cursor, err := roles.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "grantedAt", Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "user", Value: "$user"}, {Key: "role", Value: "$role"}}},
+			{Key: "ids", Value: bson.D{{Key: "$push", Value: "$_id"}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "ids.1", Value: bson.D{{Key: "$exists", Value: true}}}}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	var duplicates []struct {
+		IDs []primitive.ObjectID `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+
+	for _, d := range duplicates {
+		_, err := roles.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": d.IDs[1:]}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// derived is the Down of backfills, the stores keep writing the fields they
+// filled in so there is nothing to take back.
+func derived(ctx context.Context, db *mongo.Database) error {
+	return nil
+}
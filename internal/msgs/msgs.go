@@ -31,6 +31,11 @@ var (
 	ErrWrongFormat       = errors.New("wrong body format")
 	ErrNotAuthorized     = errors.New("credentials not authorized")
 	ErrDeleteFailed      = errors.New("failed to delete the user")
+	ErrQuerySyntax       = errors.New("malformed search query")
+	ErrDuplicateLink     = errors.New("link was already posted")
+	ErrTooLarge          = errors.New("upload is too large")
+	ErrMediaType         = errors.New("unsupported media type")
+	ErrReadOnly          = errors.New("field can't be changed")
 )
 
 // debug
@@ -55,6 +60,11 @@ var msgmap = map[error]int{
 	ErrNotFound:          http.StatusNotFound,
 	ErrNotAuthorized:     http.StatusUnauthorized,
 	ErrDeleteFailed:      http.StatusBadRequest,
+	ErrQuerySyntax:       http.StatusBadRequest,
+	ErrDuplicateLink:     http.StatusConflict,
+	ErrTooLarge:          http.StatusRequestEntityTooLarge,
+	ErrMediaType:         http.StatusUnsupportedMediaType,
+	ErrReadOnly:          http.StatusUnprocessableEntity,
 }
 
 func ReportError(err error, content string, info ...any) (int, respError) {
@@ -0,0 +1,164 @@
+// Package notify delivers notifications to the inboxes of the users an
+// action concerns.
+package notify
+
+import (
+	"context"
+	"redoot/internal/live"
+	"redoot/internal/storage"
+	"redoot/internal/types"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notifier delivers in the background so a board with many subscribers
+// doesn't hold up the request, each delivery gets timeout to finish.
+type Notifier struct {
+	users         storage.UserStore
+	notifications storage.NotificationStore
+	subscriptions storage.SubscriptionStore
+	events        live.Publisher
+	timeout       time.Duration
+	pending       sync.WaitGroup
+}
+
+func New(users storage.UserStore, notifications storage.NotificationStore, subscriptions storage.SubscriptionStore, events live.Publisher, timeout time.Duration) *Notifier {
+	return &Notifier{users: users, notifications: notifications, subscriptions: subscriptions, events: events, timeout: timeout}
+}
+
+// Wait blocks until the deliveries in flight are done.
+func (n *Notifier) Wait() {
+	n.pending.Wait()
+}
+
+func (n *Notifier) background(deliver func(ctx context.Context)) {
+	n.pending.Add(1)
+	go func() {
+		defer n.pending.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+		defer cancel()
+		deliver(ctx)
+	}()
+}
+
+// Send delivers the notifications, the first one for a recipient wins so
+// replying to someone while mentioning them notifies once. Nobody is
+// notified of their own actions or of types they muted. Failing to deliver
+// never fails the action, it's only logged.
+func (n *Notifier) Send(ctx context.Context, batch ...types.Notification) {
+	recipients := []primitive.ObjectID{}
+	for _, notification := range batch {
+		if !notification.User.IsZero() && notification.User != notification.Actor {
+			recipients = append(recipients, notification.User)
+		}
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	// users missing from the lookup were deleted and get nothing
+	users, err := n.users.Lookup(ctx, recipients)
+	if err != nil {
+		log.Error("failed loading notification preferences", "count", len(recipients), "error", err)
+		return
+	}
+
+	now := time.Now()
+	seen := map[primitive.ObjectID]bool{}
+	deliver := []types.Notification{}
+	for _, notification := range batch {
+		recipient := notification.User
+		if recipient.IsZero() || recipient == notification.Actor || seen[recipient] {
+			continue
+		}
+
+		usr, ok := users[recipient]
+		if !ok {
+			continue
+		}
+		if !usr.Muted.Wants(notification.Type) {
+			continue
+		}
+
+		seen[recipient] = true
+		notification.ID = primitive.NewObjectID()
+		notification.Read = false
+		notification.CreatedAt = now
+		deliver = append(deliver, notification)
+	}
+
+	if len(deliver) == 0 {
+		return
+	}
+	if err := n.notifications.Create(ctx, deliver); err != nil {
+		log.Error("failed delivering notifications", "count", len(deliver), "error", err)
+		return
+	}
+	for _, notification := range deliver {
+		n.events.Publish(live.New(live.Notification, notification, live.User(notification.User)))
+	}
+}
+
+// Post tells the users mentioned in a new post and the subscribers of its
+// board about it.
+func (n *Notifier) Post(post types.Post, mentioned []primitive.ObjectID) {
+	n.background(func(ctx context.Context) {
+		about := types.Notification{Actor: post.Author, Board: post.Board, Post: &post.ID}
+		batch := mentions(about, mentioned)
+
+		subscribers, err := n.subscriptions.Subscribers(ctx, post.Board)
+		if err != nil {
+			log.Error("failed listing subscribers", "board", post.Board, "error", err)
+		}
+		for _, user := range subscribers {
+			batch = append(batch, to(about, user, types.BoardPost))
+		}
+		n.Send(ctx, batch...)
+	})
+}
+
+// Comment tells the author of what the comment replies to and the users it
+// mentions about a new comment, parent is nil for top level comments.
+func (n *Notifier) Comment(post types.Post, parent *types.Comment, comment types.Comment, mentioned []primitive.ObjectID) {
+	about := types.Notification{Actor: comment.Author, Board: post.Board, Post: &post.ID, Comment: &comment.ID}
+
+	var batch []types.Notification
+	if parent != nil {
+		batch = append(batch, to(about, parent.Author, types.CommentReply))
+	} else {
+		batch = append(batch, to(about, post.Author, types.PostReply))
+	}
+	batch = append(batch, mentions(about, mentioned)...)
+	n.background(func(ctx context.Context) { n.Send(ctx, batch...) })
+}
+
+// Moderated tells the author that someone else did the action to their post
+// or comment, comment is nil for posts.
+func (n *Notifier) Moderated(actor, author, board, post primitive.ObjectID, comment *primitive.ObjectID, action string) {
+	notification := types.Notification{
+		User:    author,
+		Type:    types.Moderation,
+		Actor:   actor,
+		Board:   board,
+		Post:    &post,
+		Comment: comment,
+		Action:  action,
+	}
+	n.background(func(ctx context.Context) { n.Send(ctx, notification) })
+}
+
+func to(about types.Notification, user primitive.ObjectID, kind types.NotificationType) types.Notification {
+	about.User, about.Type = user, kind
+	return about
+}
+
+func mentions(about types.Notification, users []primitive.ObjectID) []types.Notification {
+	batch := make([]types.Notification, 0, len(users))
+	for _, user := range users {
+		batch = append(batch, to(about, user, types.Mention))
+	}
+	return batch
+}
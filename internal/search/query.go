@@ -0,0 +1,457 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"redoot/internal/types"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const dateLayout = "2006-01-02"
+
+var contentTypes = map[string]types.ContentType{
+	"text":  types.Text,
+	"image": types.Image,
+	"link":  types.Link,
+}
+
+// Range bounds an integer on both sides, a nil side is open.
+type Range struct {
+	Min *int
+	Max *int
+}
+
+func (r Range) Empty() bool {
+	return r.Min == nil && r.Max == nil
+}
+
+func (r Range) Contains(v int) bool {
+	return (r.Min == nil || v >= *r.Min) && (r.Max == nil || v <= *r.Max)
+}
+
+// Query is a parsed search. Every term and phrase has to appear and excluded
+// words or phrases must not, of the words joined by OR in a group of Any one
+// is enough. The filters narrow it down further, Since is inclusive and Until
+// exclusive.
+type Query struct {
+	Terms    []string
+	Any      [][]string
+	Phrases  []string
+	Excluded []string
+	Author   string
+	Board    string
+	Type     *types.ContentType
+	Votes    Range
+	Since    *time.Time
+	Until    *time.Time
+
+	// AuthorID and BoardID are Author and Board looked up by the caller,
+	// PostID limits comments to a post.
+	AuthorID primitive.ObjectID
+	BoardID  primitive.ObjectID
+	PostID   primitive.ObjectID
+}
+
+// SyntaxError points at the part of the query that couldn't be parsed.
+type SyntaxError struct {
+	Pos   int
+	Token string
+	Msg   string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at %d: %s", e.Msg, e.Pos, e.Token)
+}
+
+type token struct {
+	pos   int
+	text  string
+	key   string
+	value string
+	neg   bool
+	// quoted is set when the value was a phrase in double quotes.
+	quoted bool
+}
+
+// tokenize splits the input on spaces outside of double quotes.
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		if input[i] == ' ' || input[i] == '\t' || input[i] == '\n' {
+			i++
+			continue
+		}
+
+		tok := token{pos: i}
+		if input[i] == '-' {
+			tok.neg = true
+			i++
+		}
+
+		start := i
+		for i < len(input) && input[i] != ' ' && input[i] != '\t' && input[i] != '\n' && input[i] != '"' {
+			i++
+		}
+		word := input[start:i]
+
+		if i < len(input) && input[i] == '"' {
+			end := strings.IndexByte(input[i+1:], '"')
+			if end < 0 {
+				return nil, &SyntaxError{Pos: i, Token: input[i:], Msg: "unterminated quote"}
+			}
+			if word != "" && !strings.HasSuffix(word, ":") {
+				return nil, &SyntaxError{Pos: tok.pos, Token: input[tok.pos : i+end+2], Msg: "quote inside a word"}
+			}
+			tok.value = input[i+1 : i+1+end]
+			tok.quoted = true
+			i += end + 2
+		}
+
+		if key, value, ok := strings.Cut(word, ":"); ok && isKey(key) {
+			tok.key = key
+			if !tok.quoted {
+				tok.value = value
+			}
+		} else if !tok.quoted {
+			tok.value = word
+		}
+		tok.text = input[tok.pos:i]
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
+
+func isKey(key string) bool {
+	switch key {
+	case "author", "board", "type", "votes", "since", "until", "date":
+		return true
+	}
+	return false
+}
+
+// operator tells whether the token is AND or OR, only unquoted upper case
+// ones are, anything else is searched as a word.
+func (t token) operator() string {
+	if t.key != "" || t.neg || t.quoted {
+		return ""
+	}
+	switch t.value {
+	case "AND", "OR":
+		return t.value
+	}
+	return ""
+}
+
+// word is the single word OR can join, phrases, exclusions and filters
+// can't be alternatives.
+func (t token) word() (string, bool) {
+	words := strings.Fields(normalize(t.value))
+	if t.key != "" || t.neg || len(words) != 1 {
+		return "", false
+	}
+	return words[0], true
+}
+
+// Parse reads a query like `telescope "saturn rings" -moon author:Mod1
+// board:science type:image votes:>10 since:2024-01-01`. Everything is
+// joined by AND whether it's written or not, `saturn OR jupiter` matches
+// either word. Unknown keys are searched as text.
+func Parse(input string) (Query, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return Query{}, err
+	}
+
+	var q Query
+	for i, tok := range tokens {
+		fail := func(msg string) error {
+			return &SyntaxError{Pos: tok.pos, Token: tok.text, Msg: msg}
+		}
+
+		if op := tok.operator(); op != "" {
+			if i == 0 || i == len(tokens)-1 || tokens[i-1].operator() != "" || tokens[i+1].operator() != "" {
+				return Query{}, fail(op + " needs something on both sides")
+			}
+			if op == "OR" {
+				_, left := tokens[i-1].word()
+				_, right := tokens[i+1].word()
+				if !left || !right {
+					return Query{}, fail("OR only joins single words")
+				}
+			}
+			continue
+		}
+
+		// the words around OR were checked by the operator
+		if i > 0 && tokens[i-1].operator() == "OR" {
+			word, _ := tok.word()
+			last := len(q.Any) - 1
+			q.Any[last] = append(q.Any[last], word)
+			continue
+		}
+		if i < len(tokens)-1 && tokens[i+1].operator() == "OR" {
+			word, _ := tok.word()
+			q.Any = append(q.Any, []string{word})
+			continue
+		}
+
+		if tok.key != "" {
+			if tok.neg {
+				return Query{}, fail("filters can't be excluded")
+			}
+			if tok.value == "" {
+				return Query{}, fail("missing value")
+			}
+			if err := q.filter(tok.key, tok.value); err != nil {
+				return Query{}, fail(err.Error())
+			}
+			continue
+		}
+
+		words := strings.Fields(normalize(tok.value))
+		if len(words) == 0 {
+			if tok.neg || tok.quoted {
+				return Query{}, fail("nothing to search for")
+			}
+			continue
+		}
+
+		text := strings.Join(words, " ")
+		switch {
+		case tok.neg:
+			q.Excluded = append(q.Excluded, text)
+		case tok.quoted && len(words) > 1:
+			q.Phrases = append(q.Phrases, text)
+		default:
+			q.Terms = append(q.Terms, words...)
+		}
+	}
+
+	if len(q.Excluded) > 0 && !q.HasText() {
+		return Query{}, &SyntaxError{Pos: 0, Token: input, Msg: "exclusions need something to search for"}
+	}
+	if !q.HasText() && !q.HasFilters() {
+		return Query{}, &SyntaxError{Pos: 0, Token: input, Msg: "empty query"}
+	}
+	return q, nil
+}
+
+func (q *Query) filter(key, value string) error {
+	switch key {
+	case "author":
+		q.Author = value
+	case "board":
+		q.Board = value
+	case "type":
+		t, ok := contentTypes[strings.ToLower(value)]
+		if !ok {
+			return errors.New("type must be text, image or link")
+		}
+		q.Type = &t
+	case "votes":
+		r, err := parseRange(value)
+		if err != nil {
+			return err
+		}
+		q.Votes = r
+	case "since":
+		t, err := parseDate(value)
+		if err != nil {
+			return err
+		}
+		q.Since = &t
+	case "until":
+		t, err := parseDate(value)
+		if err != nil {
+			return err
+		}
+		until := endOf(value, t)
+		q.Until = &until
+	case "date":
+		from, to, ok := strings.Cut(value, "..")
+		since, err := parseDate(from)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			to = from
+		}
+		until, err := parseDate(to)
+		if err != nil {
+			return err
+		}
+		until = endOf(to, until)
+		q.Since, q.Until = &since, &until
+	}
+	return nil
+}
+
+// parseRange reads 10, >10, >=10, <10, <=10 and 10..20.
+func parseRange(value string) (Range, error) {
+	number := func(s string) (*int, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.New("expected a number")
+		}
+		return &n, nil
+	}
+	plus := func(n *int, d int) *int {
+		v := *n + d
+		return &v
+	}
+
+	var r Range
+	var err error
+	switch {
+	case strings.Contains(value, ".."):
+		from, to, _ := strings.Cut(value, "..")
+		if r.Min, err = number(from); err != nil {
+			return Range{}, err
+		}
+		if r.Max, err = number(to); err != nil {
+			return Range{}, err
+		}
+		if *r.Min > *r.Max {
+			return Range{}, errors.New("range is empty")
+		}
+	case strings.HasPrefix(value, ">="):
+		r.Min, err = number(value[2:])
+	case strings.HasPrefix(value, "<="):
+		r.Max, err = number(value[2:])
+	case strings.HasPrefix(value, ">"):
+		if r.Min, err = number(value[1:]); err == nil {
+			r.Min = plus(r.Min, 1)
+		}
+	case strings.HasPrefix(value, "<"):
+		if r.Max, err = number(value[1:]); err == nil {
+			r.Max = plus(r.Max, -1)
+		}
+	default:
+		if r.Min, err = number(strings.TrimPrefix(value, "=")); err == nil {
+			r.Max = r.Min
+		}
+	}
+	return r, err
+}
+
+// parseDate takes a day or a full RFC 3339 timestamp.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New("expected a date like 2024-01-31")
+}
+
+// endOf makes a bare day inclusive by moving to the start of the next one.
+func endOf(value string, t time.Time) time.Time {
+	if len(value) == len(dateLayout) {
+		return t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// HasText tells whether the query needs the text index at all.
+func (q Query) HasText() bool {
+	return len(q.Terms) > 0 || len(q.Any) > 0 || len(q.Phrases) > 0
+}
+
+// Clauses are the groups of words of which every one needs a match, each
+// term makes a group of its own.
+func (q Query) Clauses() [][]string {
+	clauses := [][]string{}
+	for _, term := range q.Terms {
+		clauses = append(clauses, []string{term})
+	}
+	return append(clauses, q.Any...)
+}
+
+func (q Query) HasFilters() bool {
+	return q.ContentOnly() || q.Since != nil || q.Until != nil
+}
+
+// ContentOnly tells whether a filter only posts and comments have is set,
+// type only matches posts.
+func (q Query) ContentOnly() bool {
+	return q.Author != "" || q.Board != "" || q.Type != nil || !q.Votes.Empty()
+}
+
+// Highlights are the words snippets should mark.
+func (q Query) Highlights() []string {
+	words := append([]string{}, q.Terms...)
+	for _, group := range q.Any {
+		words = append(words, group...)
+	}
+	for _, phrase := range q.Phrases {
+		words = append(words, strings.Fields(phrase)...)
+	}
+	return words
+}
+
+// TextSearch renders the text part in the syntax of a mongo $text search.
+// Mongo matches any of the words, that every clause matches is up to the
+// caller.
+func (q Query) TextSearch() string {
+	parts := append([]string{}, q.Terms...)
+	for _, group := range q.Any {
+		parts = append(parts, group...)
+	}
+	for _, phrase := range q.Phrases {
+		parts = append(parts, strconv.Quote(phrase))
+	}
+	for _, excluded := range q.Excluded {
+		if strings.Contains(excluded, " ") {
+			parts = append(parts, "-"+strconv.Quote(excluded))
+		} else {
+			parts = append(parts, "-"+excluded)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Score matches the text part against the fields the same way the text
+// index would, ok is false when the document doesn't match.
+func (q Query) Score(fields ...Field) (score float64, ok bool) {
+	if !q.HasText() {
+		return 0, true
+	}
+
+	var all strings.Builder
+	all.WriteString(" ")
+	for _, f := range fields {
+		all.WriteString(normalize(f.Text))
+		all.WriteString(" | ")
+	}
+	text := all.String()
+
+	for _, excluded := range q.Excluded {
+		if containsWords(text, excluded) {
+			return 0, false
+		}
+	}
+	for _, phrase := range q.Phrases {
+		if !containsWords(text, phrase) {
+			return 0, false
+		}
+	}
+	for _, clause := range q.Clauses() {
+		if Score(clause, fields...) == 0 {
+			return 0, false
+		}
+	}
+
+	score = Score(q.Highlights(), fields...)
+	return score, score > 0
+}
+
+// containsWords looks for the words in a normalized text, whole words only.
+func containsWords(text, words string) bool {
+	return strings.Contains(text, " "+words+" ")
+}
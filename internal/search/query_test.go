@@ -0,0 +1,98 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseOperators(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input string
+		want  Query
+	}{
+		{
+			input: "author:X AND board:Y since:2024-01-01",
+			want:  Query{Author: "X", Board: "Y", Since: &since},
+		},
+		{
+			input: "foo OR bar",
+			want:  Query{Any: [][]string{{"foo", "bar"}}},
+		},
+		{
+			input: "foo AND bar",
+			want:  Query{Terms: []string{"foo", "bar"}},
+		},
+		{
+			input: "telescope saturn OR jupiter OR mars -moon",
+			want:  Query{Terms: []string{"telescope"}, Any: [][]string{{"saturn", "jupiter", "mars"}}, Excluded: []string{"moon"}},
+		},
+		{
+			input: `"rings of saturn" AND telescope OR binoculars board:science`,
+			want:  Query{Phrases: []string{"rings of saturn"}, Any: [][]string{{"telescope", "binoculars"}}, Board: "science"},
+		},
+		{
+			input: `cats and dogs or "OR"`,
+			want:  Query{Terms: []string{"cats", "and", "dogs", "or", "or"}},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseDanglingOperators(t *testing.T) {
+	for _, input := range []string{
+		"AND foo",
+		"foo OR",
+		"OR",
+		"foo AND OR bar",
+		"foo OR OR bar",
+		"author:X OR board:Y",
+		`"saturn rings" OR jupiter`,
+		"foo OR -bar",
+	} {
+		_, err := Parse(input)
+		var syntax *SyntaxError
+		if !errors.As(err, &syntax) {
+			t.Errorf("Parse(%q) = %v, want a syntax error", input, err)
+		}
+	}
+}
+
+func TestScoreClauses(t *testing.T) {
+	fields := []Field{{Text: "A telescope pointed at Saturn", Weight: 1}}
+
+	tests := []struct {
+		input string
+		match bool
+	}{
+		{"telescope saturn", true},
+		{"telescope AND jupiter", false},
+		{"jupiter OR saturn", true},
+		{"jupiter OR mars", false},
+		{"telescope jupiter OR saturn", true},
+		{"microscope jupiter OR saturn", false},
+	}
+
+	for _, tt := range tests {
+		q, err := Parse(tt.input)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+		}
+		if _, ok := q.Score(fields...); ok != tt.match {
+			t.Errorf("Score of %q = %v, want %v", tt.input, ok, tt.match)
+		}
+	}
+}
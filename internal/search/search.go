@@ -0,0 +1,155 @@
+// Package search holds the text matching shared by the stores and the
+// handlers, the in-memory index scores with it and snippets are cut with it
+// no matter which store found the document.
+package search
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// minPrefix is the shortest term that also matches longer words, a rough
+// stand-in for the stemming mongo does.
+const minPrefix = 3
+
+// Field is a searchable text and how much a match in it counts.
+type Field struct {
+	Text   string
+	Weight float64
+}
+
+type span struct {
+	start, end int
+}
+
+// words returns the rune offsets of every word in text.
+func words(text []rune) []span {
+	var spans []span
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsNumber(r)
+		if inWord && start < 0 {
+			start = i
+		} else if !inWord && start >= 0 {
+			spans = append(spans, span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, len(text)})
+	}
+	return spans
+}
+
+// Terms splits the query into distinct lower case words.
+func Terms(query string) []string {
+	text := []rune(query)
+	seen := map[string]bool{}
+	terms := []string{}
+	for _, w := range words(text) {
+		term := strings.ToLower(string(text[w.start:w.end]))
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// normalize lower cases the words of text and joins them with single spaces.
+func normalize(text string) string {
+	runes := []rune(text)
+	var b strings.Builder
+	for i, w := range words(runes) {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(strings.ToLower(string(runes[w.start:w.end])))
+	}
+	return b.String()
+}
+
+func matchesTerm(word string, terms []string) bool {
+	word = strings.ToLower(word)
+	for _, term := range terms {
+		if word == term || (len(term) >= minPrefix && strings.HasPrefix(word, term)) {
+			return true
+		}
+	}
+	return false
+}
+
+// WordPattern is a regular expression for the words the term matches, for
+// stores that can't score on their own.
+func WordPattern(term string) string {
+	pattern := `\b` + regexp.QuoteMeta(term)
+	if len(term) < minPrefix {
+		pattern += `\b`
+	}
+	return pattern
+}
+
+// Score sums the weighted matches of the terms in the fields, zero means the
+// document doesn't match at all.
+func Score(terms []string, fields ...Field) float64 {
+	score := 0.0
+	for _, f := range fields {
+		text := []rune(f.Text)
+		for _, w := range words(text) {
+			if matchesTerm(string(text[w.start:w.end]), terms) {
+				score += f.Weight
+			}
+		}
+	}
+	return score
+}
+
+// Snippet cuts about width runes of text around the first match and wraps
+// the matching words in <mark>, everything else is HTML escaped.
+func Snippet(text string, terms []string, width int) string {
+	runes := []rune(text)
+	spans := words(runes)
+
+	first := span{}
+	for _, w := range spans {
+		if matchesTerm(string(runes[w.start:w.end]), terms) {
+			first = w
+			break
+		}
+	}
+
+	lo := max(0, first.start-width/4)
+	hi := max(min(len(runes), lo+width), first.end)
+	lo = max(0, min(lo, hi-width))
+	for lo > 0 && lo < first.start && !unicode.IsSpace(runes[lo-1]) {
+		lo++
+	}
+	for hi < len(runes) && hi > first.end && !unicode.IsSpace(runes[hi]) {
+		hi--
+	}
+
+	var b strings.Builder
+	if lo > 0 {
+		b.WriteString("…")
+	}
+	at := lo
+	for _, w := range spans {
+		if w.start < lo || w.end > hi {
+			continue
+		}
+		word := string(runes[w.start:w.end])
+		if !matchesTerm(word, terms) {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[at:w.start])))
+		b.WriteString("<mark>" + html.EscapeString(word) + "</mark>")
+		at = w.end
+	}
+	b.WriteString(html.EscapeString(string(runes[at:hi])))
+	if hi < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,289 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"redoot/internal/markdown"
+	"redoot/internal/storage"
+	"redoot/internal/types"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrNoPassword = errors.New("admin password is required to create the admin")
+
+// Admin is the account bootstrap makes sure exists and holds the admin role.
+type Admin struct {
+	Name     string
+	Email    string
+	Password string
+}
+
+// fixture is a single request body like the files in requests/ or a whole
+// export, a file can hold either.
+type fixture struct {
+	User    *types.User    `json:"user"`
+	Board   *types.Board   `json:"board"`
+	Post    *types.Post    `json:"post"`
+	Comment *types.Comment `json:"comment"`
+	storage.Dump
+}
+
+// Seeder fills the stores without ever duplicating what is already there,
+// so it can run against the same database any number of times.
+type Seeder struct {
+	stores  storage.Stores
+	cost    int
+	timeout time.Duration
+}
+
+func New(stores storage.Stores, cost int, timeout time.Duration) Seeder {
+	return Seeder{stores: stores, cost: cost, timeout: timeout}
+}
+
+// Run loads the fixtures first so an admin defined in them gets the role.
+func (s Seeder) Run(admin Admin, fixtures string) error {
+	if fixtures != "" {
+		if err := s.Fixtures(fixtures); err != nil {
+			return err
+		}
+	}
+	if admin.Name != "" {
+		return s.Admin(admin)
+	}
+	return nil
+}
+
+// Admin creates the admin unless a user with the name exists and grants it
+// the admin role.
+func (s Seeder) Admin(admin Admin) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	usr, err := s.stores.Users.ByName(ctx, admin.Name)
+	if err == storage.ErrNotFound {
+		if admin.Password == "" {
+			return ErrNoPassword
+		}
+
+		usr = types.User{
+			ID:       primitive.NewObjectID(),
+			Name:     admin.Name,
+			Email:    admin.Email,
+			Password: admin.Password,
+		}
+		if err := s.createUser(ctx, &usr); err != nil {
+			return err
+		}
+		log.Info("created admin", "name", usr.Name, "id", usr.ID.Hex())
+	} else if err != nil {
+		return err
+	}
+
+	return s.stores.Roles.Grant(ctx, usr.ID, types.RoleAdmin, usr.ID)
+}
+
+// Fixtures loads every .json file in dir in name order.
+func (s Seeder) Fixtures(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	var all storage.Dump
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var f fixture
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		if f.User != nil {
+			all.Users = append(all.Users, *f.User)
+		}
+		if f.Board != nil {
+			all.Boards = append(all.Boards, *f.Board)
+		}
+		if f.Post != nil {
+			all.Posts = append(all.Posts, *f.Post)
+		}
+		if f.Comment != nil {
+			all.Comments = append(all.Comments, *f.Comment)
+		}
+		all.Users = append(all.Users, f.Users...)
+		all.Boards = append(all.Boards, f.Boards...)
+		all.Posts = append(all.Posts, f.Posts...)
+		all.Comments = append(all.Comments, f.Comments...)
+	}
+
+	return s.Load(all)
+}
+
+// Load adds everything in the dump that doesn't exist yet. Users and boards
+// without an id are matched by name, posts and comments need an id and
+// their references or they are skipped.
+func (s Seeder) Load(dump storage.Dump) error {
+	created := map[string]int{}
+	for _, usr := range dump.Users {
+		ok, err := s.user(usr)
+		if err != nil {
+			return err
+		} else if ok {
+			created["users"]++
+		}
+	}
+
+	for _, board := range dump.Boards {
+		ok, err := s.board(board)
+		if err != nil {
+			return err
+		} else if ok {
+			created["boards"]++
+		}
+	}
+
+	for _, post := range dump.Posts {
+		ok, err := s.post(post)
+		if err != nil {
+			return err
+		} else if ok {
+			created["posts"]++
+		}
+	}
+
+	for _, comment := range dump.Comments {
+		ok, err := s.comment(comment)
+		if err != nil {
+			return err
+		} else if ok {
+			created["comments"]++
+		}
+	}
+
+	log.Info("loaded fixtures", "users", created["users"], "boards", created["boards"], "posts", created["posts"], "comments", created["comments"])
+	return nil
+}
+
+// createUser hashes the password unless it already is a bcrypt hash, as it
+// is in an export.
+func (s Seeder) createUser(ctx context.Context, usr *types.User) error {
+	if _, err := bcrypt.Cost([]byte(usr.Password)); err != nil {
+		hash, err := bcrypt.GenerateFromPassword([]byte(usr.Password), s.cost)
+		if err != nil {
+			return err
+		}
+		usr.Password = string(hash)
+	}
+
+	id, err := s.stores.Users.Create(ctx, *usr)
+	usr.ID = id
+	return err
+}
+
+func (s Seeder) user(usr types.User) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	if !usr.ID.IsZero() {
+		_, err := s.stores.Users.Get(ctx, usr.ID)
+		if err != storage.ErrNotFound {
+			return false, err
+		}
+	}
+
+	_, err := s.stores.Users.ByName(ctx, usr.Name)
+	if err != storage.ErrNotFound {
+		return false, err
+	}
+
+	return true, s.createUser(ctx, &usr)
+}
+
+func (s Seeder) board(board types.Board) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	board.Deletion = types.Deletion{}
+
+	if !board.ID.IsZero() {
+		_, err := s.stores.Boards.GetAny(ctx, board.ID)
+		if err != storage.ErrNotFound {
+			return false, err
+		}
+	} else {
+		named, err := s.stores.Boards.Search(ctx, "name", "^"+regexp.QuoteMeta(board.Name)+"$")
+		if err != nil {
+			return false, err
+		}
+		for _, b := range named {
+			if b.Name == board.Name {
+				return false, nil
+			}
+		}
+	}
+
+	_, err := s.stores.Boards.Create(ctx, board)
+	return err == nil, err
+}
+
+func (s Seeder) post(post types.Post) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	if post.ID.IsZero() || post.Board.IsZero() || post.Author.IsZero() {
+		log.Warn("skipping post fixture without id, board or author", "title", post.Title)
+		return false, nil
+	}
+
+	_, err := s.stores.Posts.GetAny(ctx, post.Board, post.ID)
+	if err != storage.ErrNotFound {
+		return false, err
+	}
+
+	post.Votes, post.Ups, post.Downs, post.Controversy = 0, 0, 0, 0
+	post.Deletion = types.Deletion{}
+	// fixtures don't link their mentions, the next edit does
+	if post.BodyType == types.Text && post.BodyHTML == "" {
+		post.BodyHTML = markdown.Render(post.BodyContent, markdown.Links{})
+	}
+	_, err = s.stores.Posts.Create(ctx, post)
+	return err == nil, err
+}
+
+func (s Seeder) comment(comment types.Comment) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	if comment.ID.IsZero() || comment.Post.IsZero() || comment.Author.IsZero() {
+		log.Warn("skipping comment fixture without id, post or author", "body", comment.Body)
+		return false, nil
+	}
+
+	_, err := s.stores.Comments.GetAny(ctx, comment.Post, comment.ID)
+	if err != storage.ErrNotFound {
+		return false, err
+	}
+
+	comment.Votes, comment.Ups, comment.Downs, comment.Controversy = 0, 0, 0, 0
+	comment.Deletion = types.Deletion{}
+	comment.Purged = false
+	if comment.BodyHTML == "" {
+		comment.BodyHTML = markdown.Render(comment.Body, markdown.Links{})
+	}
+	_, err = s.stores.Comments.Create(ctx, comment)
+	return err == nil, err
+}
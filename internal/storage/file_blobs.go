@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"redoot/internal/types"
+	"regexp"
+)
+
+// blobId keeps ids from escaping the directory, they are a sha256 in hex
+// with an optional variant like -thumb.
+var blobId = regexp.MustCompile(`^[0-9a-f]{64}(-[a-z]+)?$`)
+
+type fileBlobs struct {
+	dir string
+}
+
+// NewFiles keeps blobs in dir, fanned out into subdirectories by the first
+// two characters of the id with the description in a .json next to the bytes.
+func NewFiles(dir string) (BlobStore, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return fileBlobs{dir}, nil
+}
+
+func (f fileBlobs) path(id string) (string, error) {
+	if !blobId.MatchString(id) {
+		return "", ErrNotFound
+	}
+	return filepath.Join(f.dir, id[:2], id), nil
+}
+
+func (f fileBlobs) Put(ctx context.Context, media types.Media, data []byte) error {
+	path, err := f.path(media.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stat(path + ".json")
+	if err == nil {
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	meta, err := json.Marshal(media)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	// the description goes last, a blob without one was never completely
+	// written and gets overwritten by the next put
+	err = writeAtomic(path, data)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path+".json", meta)
+}
+
+func (f fileBlobs) Get(ctx context.Context, id string) (types.Media, []byte, error) {
+	media, err := f.Stat(ctx, id)
+	if err != nil {
+		return types.Media{}, nil, err
+	}
+
+	path, _ := f.path(id)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return types.Media{}, nil, ErrNotFound
+	}
+	return media, data, err
+}
+
+func (f fileBlobs) Stat(ctx context.Context, id string) (types.Media, error) {
+	path, err := f.path(id)
+	if err != nil {
+		return types.Media{}, err
+	}
+
+	raw, err := os.ReadFile(path + ".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return types.Media{}, ErrNotFound
+	} else if err != nil {
+		return types.Media{}, err
+	}
+
+	var media types.Media
+	err = json.Unmarshal(raw, &media)
+	return media, err
+}
+
+// writeAtomic writes to a temporary file first so readers never see half a
+// file.
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
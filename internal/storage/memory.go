@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"redoot/internal/search"
+	"redoot/internal/types"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrDuplicate = errors.New("id already exists")
+
+type voteKey struct {
+	user   primitive.ObjectID
+	target primitive.ObjectID
+}
+
+type roleKey struct {
+	user primitive.ObjectID
+	role types.RoleName
+}
+
+type subscriptionKey struct {
+	user  primitive.ObjectID
+	board primitive.ObjectID
+}
+
+// memoryDB keeps everything in maps behind a single lock, so operations
+// spanning several stores are as atomic as the mongo transactions.
+type memoryDB struct {
+	mu       sync.RWMutex
+	users    map[primitive.ObjectID]types.User
+	boards   map[primitive.ObjectID]types.Board
+	posts    map[primitive.ObjectID]types.Post
+	comments map[primitive.ObjectID]types.Comment
+	votes    map[voteKey]types.Vote
+	sessions map[primitive.ObjectID]types.Session
+	roles    map[roleKey]types.Role
+	audit    []types.RoleAudit
+
+	notifications map[primitive.ObjectID]types.Notification
+	subscriptions map[subscriptionKey]types.Subscription
+	revisions     map[primitive.ObjectID]types.Revision
+}
+
+// NewMemory keeps everything in process memory, meant for local development
+// and tests. Nothing survives a restart.
+func NewMemory() Stores {
+	m := &memoryDB{
+		users:    map[primitive.ObjectID]types.User{},
+		boards:   map[primitive.ObjectID]types.Board{},
+		posts:    map[primitive.ObjectID]types.Post{},
+		comments: map[primitive.ObjectID]types.Comment{},
+		votes:    map[voteKey]types.Vote{},
+		sessions: map[primitive.ObjectID]types.Session{},
+		roles:    map[roleKey]types.Role{},
+
+		notifications: map[primitive.ObjectID]types.Notification{},
+		subscriptions: map[subscriptionKey]types.Subscription{},
+		revisions:     map[primitive.ObjectID]types.Revision{},
+	}
+
+	return Stores{
+		Users:    memoryUsers{m},
+		Boards:   memoryBoards{m},
+		Posts:    memoryPosts{m},
+		Comments: memoryComments{m},
+		Votes:    memoryVotes{m},
+		Sessions: memorySessions{m},
+		Roles:    memoryRoles{m},
+		Backup:   memoryBackup{m},
+
+		Notifications: memoryNotifications{m},
+		Subscriptions: memorySubscriptions{m},
+		Revisions:     memoryRevisions{m},
+	}
+}
+
+func newId(id primitive.ObjectID) primitive.ObjectID {
+	if id.IsZero() {
+		return primitive.NewObjectID()
+	}
+	return id
+}
+
+// newer orders ids descending, the same way mongo sorts _id: -1.
+func newer(a, b primitive.ObjectID) bool {
+	return bytes.Compare(a[:], b[:]) > 0
+}
+
+// values returns the documents passing keep ordered by id, oldest first like
+// the natural order of a collection.
+func values[T any](docs map[primitive.ObjectID]T, id func(T) primitive.ObjectID, keep func(T) bool) []T {
+	result := []T{}
+	for _, doc := range docs {
+		if keep == nil || keep(doc) {
+			result = append(result, doc)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return newer(id(result[j]), id(result[i])) })
+	return result
+}
+
+// paginate sorts the items descending by key and returns up to p.Limit+1
+// of them after the cursor.
+func paginate[T any](items []T, p Page, key func(T) (float64, primitive.ObjectID)) []T {
+	sort.SliceStable(items, func(i, j int) bool {
+		vi, idi := key(items[i])
+		vj, idj := key(items[j])
+		if vi != vj {
+			return vi > vj
+		}
+		return newer(idi, idj)
+	})
+
+	result := []T{}
+	for _, item := range items {
+		value, id := key(item)
+		if p.Since != nil && id.Timestamp().Before(*p.Since) {
+			continue
+		}
+		if p.After != nil {
+			if value > p.After.Value || (value == p.After.Value && !newer(p.After.ID, id)) {
+				continue
+			}
+		}
+		result = append(result, item)
+		if int64(len(result)) > p.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// scoreText ranks the documents the way the text indexes do, fields returns
+// what is searchable in a document.
+func scoreText[T any](docs []T, q search.Query, p Page, id func(T) primitive.ObjectID, fields func(T) []search.Field) []Scored[T] {
+	scored := []Scored[T]{}
+	for _, doc := range docs {
+		if !createdWithin(q, id(doc)) {
+			continue
+		}
+		if score, ok := q.Score(fields(doc)...); ok {
+			scored = append(scored, Scored[T]{Doc: doc, Score: score})
+		}
+	}
+
+	return paginate(scored, p, func(s Scored[T]) (float64, primitive.ObjectID) {
+		return s.Score, id(s.Doc)
+	})
+}
+
+// createdWithin checks the creation time in the id against the dates of the
+// query.
+func createdWithin(q search.Query, id primitive.ObjectID) bool {
+	created := id.Timestamp()
+	return (q.Since == nil || !created.Before(*q.Since)) && (q.Until == nil || created.Before(*q.Until))
+}
+
+func scoreKey(sort string, votes int, controversy float64) float64 {
+	switch sort {
+	case SortVotes:
+		return float64(votes)
+	case SortControversy:
+		return controversy
+	}
+	return 0
+}
+
+func postKey(p Page) func(types.Post) (float64, primitive.ObjectID) {
+	return func(post types.Post) (float64, primitive.ObjectID) {
+		switch p.Sort {
+		case SortHot:
+			return post.Hot, post.ID
+		case SortRising:
+			return post.Rising, post.ID
+		}
+		return scoreKey(p.Sort, post.Votes, post.Controversy), post.ID
+	}
+}
+
+func commentKey(p Page) func(types.Comment) (float64, primitive.ObjectID) {
+	return func(comment types.Comment) (float64, primitive.ObjectID) {
+		return scoreKey(p.Sort, comment.Votes, comment.Controversy), comment.ID
+	}
+}
+
+// matches applies the same case insensitive regex the mongo search uses to
+// the field of the document as it would be stored.
+func matches(doc any, field string, pattern *regexp.Regexp) bool {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return false
+	}
+
+	var fields bson.M
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		return false
+	}
+
+	value, ok := fields[field].(string)
+	return ok && pattern.MatchString(value)
+}
+
+func searchPattern(value string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + value)
+}
+
+func live(d types.Deletion) bool {
+	return d.DeletedAt == nil
+}
+
+func markDeleted(d *types.Deletion, by primitive.ObjectID, with *primitive.ObjectID, at time.Time) {
+	d.DeletedAt = &at
+	d.DeletedBy = &by
+	d.DeletedWith = with
+}
+
+// restorableSince reports whether the document was deleted on its own after
+// since, documents deleted with their board or post come back with it.
+func restorableSince(d types.Deletion, since time.Time) bool {
+	return d.DeletedAt != nil && !d.DeletedAt.Before(since) && d.DeletedWith == nil
+}
+
+func deletedWith(d types.Deletion, id primitive.ObjectID) bool {
+	return d.DeletedWith != nil && *d.DeletedWith == id
+}
+
+// cascadePosts deletes the posts passing keep together with their comments
+// and every vote cast on either, the lock has to be held.
+func (m *memoryDB) cascadePosts(keep func(types.Post) bool) int {
+	deleted := 0
+	for id, post := range m.posts {
+		if !keep(post) {
+			continue
+		}
+		for commentId, comment := range m.comments {
+			if comment.Post == id {
+				m.dropVotes(commentId)
+				delete(m.comments, commentId)
+			}
+		}
+		m.dropVotes(id)
+		m.dropNotifications(func(n types.Notification) bool { return n.Post != nil && *n.Post == id })
+		m.dropRevisions(func(r types.Revision) bool { return r.Post == id })
+		delete(m.posts, id)
+		deleted++
+	}
+	return deleted
+}
+
+func (m *memoryDB) dropNotifications(keep func(types.Notification) bool) {
+	for id, notification := range m.notifications {
+		if keep(notification) {
+			delete(m.notifications, id)
+		}
+	}
+}
+
+func (m *memoryDB) dropRevisions(keep func(types.Revision) bool) {
+	for id, revision := range m.revisions {
+		if keep(revision) {
+			delete(m.revisions, id)
+		}
+	}
+}
+
+func (m *memoryDB) dropVotes(target primitive.ObjectID) {
+	for key := range m.votes {
+		if key.target == target {
+			delete(m.votes, key)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/types"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryBackup struct{ *memoryDB }
+
+func (m memoryBackup) Export(ctx context.Context) (Dump, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return Dump{
+		Users:    values(m.users, userId, nil),
+		Boards:   values(m.boards, boardId, nil),
+		Posts:    values(m.posts, postId, nil),
+		Comments: values(m.comments, commentId, nil),
+	}, nil
+}
+
+func load[T any](docs map[primitive.ObjectID]T, items []T, id func(T) primitive.ObjectID, set func(*T, primitive.ObjectID)) error {
+	for _, item := range items {
+		key := newId(id(item))
+		if _, ok := docs[key]; ok {
+			return ErrDuplicate
+		}
+		set(&item, key)
+		docs[key] = item
+	}
+	return nil
+}
+
+// Import stops at the first duplicate id, like the ordered InsertMany of
+// the mongo import everything before it stays.
+func (m memoryBackup) Import(ctx context.Context, dump Dump) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := load(m.users, dump.Users, userId, func(u *types.User, id primitive.ObjectID) {
+		u.ID = id
+		backdate(&u.Timestamps, id)
+	})
+	if err != nil {
+		return err
+	}
+	err = load(m.boards, dump.Boards, boardId, func(b *types.Board, id primitive.ObjectID) {
+		b.ID = id
+		backdate(&b.Timestamps, id)
+	})
+	if err != nil {
+		return err
+	}
+	err = load(m.posts, dump.Posts, postId, func(p *types.Post, id primitive.ObjectID) {
+		p.ID = id
+		p.Hot = hot(p.Votes, id)
+		backdate(&p.Timestamps, id)
+	})
+	if err != nil {
+		return err
+	}
+	return load(m.comments, dump.Comments, commentId, func(c *types.Comment, id primitive.ObjectID) {
+		c.ID = id
+		backdate(&c.Timestamps, id)
+	})
+}
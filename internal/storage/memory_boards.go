@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/search"
+	"redoot/internal/types"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryBoards struct{ *memoryDB }
+
+func boardId(b types.Board) primitive.ObjectID { return b.ID }
+
+// boardFields mirrors the weights of the boards text index.
+func boardFields(b types.Board) []search.Field {
+	return []search.Field{{Text: b.Name, Weight: 10}, {Text: b.Bio, Weight: 2}, {Text: b.Rules, Weight: 1}}
+}
+
+func (m memoryBoards) Create(ctx context.Context, board types.Board) (primitive.ObjectID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	board.ID = newId(board.ID)
+	if _, ok := m.boards[board.ID]; ok {
+		return primitive.NilObjectID, ErrDuplicate
+	}
+	created(&board.Timestamps)
+	m.boards[board.ID] = board
+	return board.ID, nil
+}
+
+func (m memoryBoards) Get(ctx context.Context, id primitive.ObjectID) (types.Board, error) {
+	board, err := m.GetAny(ctx, id)
+	if err == nil && !live(board.Deletion) {
+		return types.Board{}, ErrNotFound
+	}
+	return board, err
+}
+
+func (m memoryBoards) GetAny(ctx context.Context, id primitive.ObjectID) (types.Board, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	board, ok := m.boards[id]
+	if !ok {
+		return types.Board{}, ErrNotFound
+	}
+	return board, nil
+}
+
+func (m memoryBoards) List(ctx context.Context, p Page) ([]types.Board, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	boards := values(m.boards, boardId, func(b types.Board) bool { return live(b.Deletion) })
+	return paginate(boards, p, func(b types.Board) (float64, primitive.ObjectID) {
+		return 0, b.ID
+	}), nil
+}
+
+func (m memoryBoards) Update(ctx context.Context, id primitive.ObjectID, board types.Board) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.boards[id]
+	if !ok || !live(old.Deletion) {
+		return ErrNotFound
+	}
+
+	board.ID = id
+	board.Deletion = old.Deletion
+	board.Timestamps = old.Timestamps
+	if reflect.DeepEqual(board, old) {
+		return ErrNotModified
+	}
+	board.UpdatedAt = stamp()
+	m.boards[id] = board
+	return nil
+}
+
+func (m memoryBoards) Search(ctx context.Context, field, value string) ([]types.Board, error) {
+	pattern, err := searchPattern(value)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return values(m.boards, boardId, func(b types.Board) bool {
+		return live(b.Deletion) && matches(b, field, pattern)
+	}), nil
+}
+
+func (m memoryBoards) Text(ctx context.Context, q search.Query, p Page) ([]Scored[types.Board], error) {
+	if q.ContentOnly() {
+		return []Scored[types.Board]{}, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	docs := values(m.boards, boardId, func(b types.Board) bool { return live(b.Deletion) })
+	return scoreText(docs, q, p, boardId, boardFields), nil
+}
+
+func (m memoryBoards) SoftDelete(ctx context.Context, id, by primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	board, ok := m.boards[id]
+	if !ok || !live(board.Deletion) {
+		return ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	markDeleted(&board.Deletion, by, nil, now)
+	m.boards[id] = board
+
+	postIds := map[primitive.ObjectID]bool{}
+	for postId, post := range m.posts {
+		if post.Board != id {
+			continue
+		}
+		postIds[postId] = true
+		if live(post.Deletion) {
+			markDeleted(&post.Deletion, by, &id, now)
+			m.posts[postId] = post
+		}
+	}
+
+	for commentId, comment := range m.comments {
+		if postIds[comment.Post] && live(comment.Deletion) {
+			markDeleted(&comment.Deletion, by, &id, now)
+			m.comments[commentId] = comment
+		}
+	}
+	return nil
+}
+
+func (m memoryBoards) Restore(ctx context.Context, id primitive.ObjectID, since time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	board, ok := m.boards[id]
+	if !ok || !restorableSince(board.Deletion, since) {
+		return ErrNotFound
+	}
+	board.Deletion = types.Deletion{}
+	m.boards[id] = board
+
+	m.restoreWith(id)
+	return nil
+}
+
+// restoreWith brings back the posts and comments deleted together with the
+// board or post, the lock has to be held.
+func (m *memoryDB) restoreWith(id primitive.ObjectID) {
+	for postId, post := range m.posts {
+		if deletedWith(post.Deletion, id) {
+			post.Deletion = types.Deletion{}
+			m.posts[postId] = post
+		}
+	}
+	for commentId, comment := range m.comments {
+		if deletedWith(comment.Deletion, id) {
+			comment.Deletion = types.Deletion{}
+			m.comments[commentId] = comment
+		}
+	}
+}
+
+func (m memoryBoards) Purge(ctx context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purged := 0
+	for id, board := range m.boards {
+		if board.DeletedAt == nil || !board.DeletedAt.Before(before) {
+			continue
+		}
+		m.cascadePosts(func(p types.Post) bool { return p.Board == id })
+		m.dropNotifications(func(n types.Notification) bool { return n.Board == id })
+		for key := range m.subscriptions {
+			if key.board == id {
+				delete(m.subscriptions, key)
+			}
+		}
+		delete(m.boards, id)
+		purged++
+	}
+	return purged, nil
+}
@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/search"
+	"redoot/internal/types"
+	"reflect"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryComments struct{ *memoryDB }
+
+func commentId(c types.Comment) primitive.ObjectID { return c.ID }
+
+// commentFields mirrors the comments text index.
+func commentFields(c types.Comment) []search.Field {
+	return []search.Field{{Text: c.Body, Weight: 1}}
+}
+
+func (m memoryComments) Create(ctx context.Context, comment types.Comment) (primitive.ObjectID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comment.ID = newId(comment.ID)
+	if _, ok := m.comments[comment.ID]; ok {
+		return primitive.NilObjectID, ErrDuplicate
+	}
+	created(&comment.Timestamps)
+	m.comments[comment.ID] = comment
+
+	if post, ok := m.posts[comment.Post]; ok {
+		post.Comments++
+		post.Rising = rising(post.Votes, post.Comments, post.ID, time.Now())
+		m.posts[post.ID] = post
+	}
+	return comment.ID, nil
+}
+
+func (m memoryComments) Get(ctx context.Context, post, id primitive.ObjectID) (types.Comment, error) {
+	comment, err := m.GetAny(ctx, post, id)
+	if err == nil && !live(comment.Deletion) {
+		return types.Comment{}, ErrNotFound
+	}
+	return comment, err
+}
+
+func (m memoryComments) GetAny(ctx context.Context, post, id primitive.ObjectID) (types.Comment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	comment, ok := m.comments[id]
+	if !ok || comment.Post != post {
+		return types.Comment{}, ErrNotFound
+	}
+	return comment, nil
+}
+
+func (m memoryComments) List(ctx context.Context, post primitive.ObjectID, p Page) ([]types.Comment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	comments := values(m.comments, commentId, func(c types.Comment) bool {
+		return c.Post == post && live(c.Deletion)
+	})
+	return paginate(comments, p, commentKey(p)), nil
+}
+
+func (m memoryComments) Thread(ctx context.Context, post primitive.ObjectID, parent *primitive.ObjectID, p Page) ([]types.Comment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	comments := values(m.comments, commentId, func(c types.Comment) bool {
+		if c.Post != post {
+			return false
+		}
+		if parent == nil {
+			return c.Parent == nil
+		}
+		return c.Parent != nil && *c.Parent == *parent
+	})
+	return paginate(comments, p, commentKey(p)), nil
+}
+
+func (m memoryComments) Replies(ctx context.Context, parents []primitive.ObjectID, p Page) ([]types.Comment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wanted := map[primitive.ObjectID]bool{}
+	for _, id := range parents {
+		wanted[id] = true
+	}
+
+	replies := values(m.comments, commentId, func(c types.Comment) bool {
+		return c.Parent != nil && wanted[*c.Parent]
+	})
+
+	key := commentKey(p)
+	sort.SliceStable(replies, func(i, j int) bool {
+		vi, idi := key(replies[i])
+		vj, idj := key(replies[j])
+		if vi != vj {
+			return vi > vj
+		}
+		return newer(idi, idj)
+	})
+
+	limited := []types.Comment{}
+	counts := map[primitive.ObjectID]int64{}
+	for _, reply := range replies {
+		if counts[*reply.Parent] <= p.Limit {
+			counts[*reply.Parent]++
+			limited = append(limited, reply)
+		}
+	}
+	return limited, nil
+}
+
+func (m memoryComments) Answered(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wanted := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	answered := map[primitive.ObjectID]bool{}
+	for _, comment := range m.comments {
+		if comment.Parent != nil && wanted[*comment.Parent] {
+			answered[*comment.Parent] = true
+		}
+	}
+	return answered, nil
+}
+
+func (m memoryComments) Update(ctx context.Context, id primitive.ObjectID, comment types.Comment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.comments[id]
+	if !ok || !live(old.Deletion) {
+		return ErrNotFound
+	}
+
+	comment.ID = id
+	comment.Deletion = old.Deletion
+	comment.Purged = old.Purged
+	comment.MyVote = 0
+	// the counters only change through votes
+	comment.Votes, comment.Ups, comment.Downs, comment.Controversy = old.Votes, old.Ups, old.Downs, old.Controversy
+	if comment.Parent == nil {
+		comment.Parent = old.Parent
+	}
+	comment.Timestamps = old.Timestamps
+	if reflect.DeepEqual(comment, old) {
+		return ErrNotModified
+	}
+	comment.UpdatedAt = stamp()
+	m.comments[id] = comment
+	return nil
+}
+
+func (m memoryComments) SoftDelete(ctx context.Context, post, id, by primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comment, ok := m.comments[id]
+	if !ok || comment.Post != post || !live(comment.Deletion) {
+		return ErrNotFound
+	}
+
+	markDeleted(&comment.Deletion, by, nil, time.Now().UTC())
+	m.comments[id] = comment
+	return nil
+}
+
+func (m memoryComments) Restore(ctx context.Context, post, id primitive.ObjectID, since time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comment, ok := m.comments[id]
+	if !ok || comment.Post != post || comment.Purged || !restorableSince(comment.Deletion, since) {
+		return ErrNotFound
+	}
+
+	comment.Deletion = types.Deletion{}
+	m.comments[id] = comment
+	return nil
+}
+
+func (m memoryComments) Text(ctx context.Context, q search.Query, p Page) ([]Scored[types.Comment], error) {
+	if q.Type != nil {
+		return []Scored[types.Comment]{}, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	docs := values(m.comments, commentId, func(c types.Comment) bool {
+		return live(c.Deletion) &&
+			(q.Author == "" || c.Author == q.AuthorID) &&
+			(q.Board == "" || m.posts[c.Post].Board == q.BoardID) &&
+			(q.PostID.IsZero() || c.Post == q.PostID) &&
+			q.Votes.Contains(c.Votes)
+	})
+	return scoreText(docs, q, p, commentId, commentFields), nil
+}
+
+func (m memoryComments) Trash(ctx context.Context, board primitive.ObjectID, since time.Time, limit int64) ([]types.Comment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	comments := values(m.comments, commentId, func(c types.Comment) bool {
+		post, ok := m.posts[c.Post]
+		return ok && post.Board == board && live(post.Deletion) &&
+			!c.Purged && restorableSince(c.Deletion, since)
+	})
+	sort.SliceStable(comments, func(i, j int) bool { return comments[i].DeletedAt.After(*comments[j].DeletedAt) })
+
+	if int64(len(comments)) > limit {
+		comments = comments[:limit]
+	}
+	return comments, nil
+}
+
+func (m memoryComments) Purge(ctx context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	answered := map[primitive.ObjectID]bool{}
+	for _, comment := range m.comments {
+		if comment.Parent != nil {
+			answered[*comment.Parent] = true
+		}
+	}
+
+	purged := 0
+	for id, comment := range m.comments {
+		if comment.Purged || comment.DeletedAt == nil || !comment.DeletedAt.Before(before) {
+			continue
+		}
+		purged++
+		m.dropRevisions(func(r types.Revision) bool { return r.Comment != nil && *r.Comment == id })
+
+		if answered[id] {
+			comment.Body = DeletedPlaceholder
+			comment.BodyHTML = DeletedPlaceholderHTML
+			comment.Author = primitive.NilObjectID
+			comment.Purged = true
+			m.comments[id] = comment
+			continue
+		}
+		m.dropVotes(id)
+		delete(m.comments, id)
+	}
+	return purged, nil
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/types"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryNotifications struct{ *memoryDB }
+
+func notificationId(n types.Notification) primitive.ObjectID { return n.ID }
+
+func (m memoryNotifications) Create(ctx context.Context, notifications []types.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, notification := range notifications {
+		notification.ID = newId(notification.ID)
+		if _, ok := m.notifications[notification.ID]; ok {
+			return ErrDuplicate
+		}
+		m.notifications[notification.ID] = notification
+	}
+	return nil
+}
+
+func (m memoryNotifications) List(ctx context.Context, user primitive.ObjectID, unread bool, kind types.NotificationType, p Page) ([]types.Notification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matching := values(m.notifications, notificationId, func(n types.Notification) bool {
+		return n.User == user && !(unread && n.Read) && (kind == "" || n.Type == kind)
+	})
+	return paginate(matching, Page{Limit: p.Limit, After: p.After}, func(n types.Notification) (float64, primitive.ObjectID) {
+		return 0, n.ID
+	}), nil
+}
+
+func (m memoryNotifications) Unread(ctx context.Context, user primitive.ObjectID) (map[types.NotificationType]int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := map[types.NotificationType]int{}
+	for _, notification := range m.notifications {
+		if notification.User == user && !notification.Read {
+			counts[notification.Type]++
+		}
+	}
+	return counts, nil
+}
+
+func (m memoryNotifications) MarkRead(ctx context.Context, user, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	notification, ok := m.notifications[id]
+	if !ok || notification.User != user {
+		return ErrNotFound
+	}
+	notification.Read = true
+	m.notifications[id] = notification
+	return nil
+}
+
+func (m memoryNotifications) MarkAllRead(ctx context.Context, user primitive.ObjectID) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	marked := 0
+	for id, notification := range m.notifications {
+		if notification.User == user && !notification.Read {
+			notification.Read = true
+			m.notifications[id] = notification
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+type memorySubscriptions struct{ *memoryDB }
+
+func (m memorySubscriptions) Subscribe(ctx context.Context, user, board primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := subscriptionKey{user: user, board: board}
+	if _, ok := m.subscriptions[key]; ok {
+		return nil
+	}
+	m.subscriptions[key] = types.Subscription{
+		ID:        primitive.NewObjectID(),
+		User:      user,
+		Board:     board,
+		CreatedAt: time.Now(),
+	}
+	return nil
+}
+
+func (m memorySubscriptions) Unsubscribe(ctx context.Context, user, board primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := subscriptionKey{user: user, board: board}
+	if _, ok := m.subscriptions[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.subscriptions, key)
+	return nil
+}
+
+func (m memorySubscriptions) Subscribers(ctx context.Context, board primitive.ObjectID) ([]primitive.ObjectID, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users := []primitive.ObjectID{}
+	for key := range m.subscriptions {
+		if key.board == board {
+			users = append(users, key.user)
+		}
+	}
+	return users, nil
+}
+
+func (m memorySubscriptions) Of(ctx context.Context, user primitive.ObjectID) ([]types.Subscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	subs := []types.Subscription{}
+	for key, sub := range m.subscriptions {
+		if key.user == user {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return newer(subs[i].ID, subs[j].ID) })
+	return subs, nil
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/search"
+	"redoot/internal/types"
+	"reflect"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryPosts struct{ *memoryDB }
+
+func postId(p types.Post) primitive.ObjectID { return p.ID }
+
+// postFields mirrors the weights of the posts text index.
+func postFields(p types.Post) []search.Field {
+	return []search.Field{{Text: p.Title, Weight: 10}, {Text: p.BodyContent, Weight: 1}}
+}
+
+func (m memoryPosts) Create(ctx context.Context, post types.Post) (primitive.ObjectID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	post.ID = newId(post.ID)
+	post.Hot = hot(post.Votes, post.ID)
+	if _, ok := m.posts[post.ID]; ok {
+		return primitive.NilObjectID, ErrDuplicate
+	}
+	created(&post.Timestamps)
+	m.posts[post.ID] = post
+	return post.ID, nil
+}
+
+func (m memoryPosts) Get(ctx context.Context, board, id primitive.ObjectID) (types.Post, error) {
+	post, err := m.GetAny(ctx, board, id)
+	if err == nil && !live(post.Deletion) {
+		return types.Post{}, ErrNotFound
+	}
+	return post, err
+}
+
+func (m memoryPosts) GetAny(ctx context.Context, board, id primitive.ObjectID) (types.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	post, ok := m.posts[id]
+	if !ok || post.Board != board {
+		return types.Post{}, ErrNotFound
+	}
+	return post, nil
+}
+
+func (m memoryPosts) List(ctx context.Context, board primitive.ObjectID, p Page) ([]types.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	posts := values(m.posts, postId, func(post types.Post) bool {
+		return post.Board == board && live(post.Deletion)
+	})
+	return paginate(posts, p, postKey(p)), nil
+}
+
+func (m memoryPosts) Popular(ctx context.Context, p Page) ([]types.NicePost, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.nice(func(post types.Post) bool { return true }, p), nil
+}
+
+func (m memoryPosts) Feed(ctx context.Context, boards []primitive.ObjectID, p Page) ([]types.NicePost, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wanted := map[primitive.ObjectID]bool{}
+	for _, board := range boards {
+		wanted[board] = true
+	}
+	return m.nice(func(post types.Post) bool { return wanted[post.Board] }, p), nil
+}
+
+// nice pages through the live posts passing keep with author and board
+// names, the caller holds the lock.
+func (m memoryPosts) nice(keep func(types.Post) bool, p Page) []types.NicePost {
+	posts := values(m.posts, postId, func(post types.Post) bool { return live(post.Deletion) && keep(post) })
+
+	nice := []types.NicePost{}
+	for _, post := range paginate(posts, p, postKey(p)) {
+		author := DeletedPlaceholder
+		if usr, ok := m.users[post.Author]; ok {
+			author = usr.Name
+		}
+		nice = append(nice, types.NicePost{
+			ID:          post.ID,
+			Title:       post.Title,
+			BodyType:    post.BodyType,
+			BodyContent: post.BodyContent,
+			BodyHTML:    post.BodyHTML,
+			Author:      author,
+			Votes:       post.Votes,
+			Controversy: post.Controversy,
+			Hot:         post.Hot,
+			Rising:      post.Rising,
+			Comments:    post.Comments,
+			Board:       m.boards[post.Board].Name,
+			CreatedAt:   post.CreatedAt,
+		})
+	}
+	return nice
+}
+
+func (m memoryPosts) Update(ctx context.Context, id primitive.ObjectID, post types.Post) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.posts[id]
+	if !ok || !live(old.Deletion) {
+		return ErrNotFound
+	}
+
+	post.ID = id
+	post.Deletion = old.Deletion
+	post.MyVote = 0
+	// the counters only change through votes and comments
+	post.Votes, post.Ups, post.Downs, post.Controversy = old.Votes, old.Ups, old.Downs, old.Controversy
+	post.Hot, post.Rising, post.Comments = old.Hot, old.Rising, old.Comments
+	post.Timestamps = old.Timestamps
+	if reflect.DeepEqual(post, old) {
+		return ErrNotModified
+	}
+	post.UpdatedAt = stamp()
+	m.posts[id] = post
+	return nil
+}
+
+func (m memoryPosts) ByLink(ctx context.Context, board primitive.ObjectID, link string) (types.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, post := range m.posts {
+		if post.Board == board && post.BodyType == types.Link && post.BodyContent == link && live(post.Deletion) {
+			return post, nil
+		}
+	}
+	return types.Post{}, ErrNotFound
+}
+
+func (m memoryPosts) Lookup(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]types.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := map[primitive.ObjectID]types.Post{}
+	for _, id := range ids {
+		if post, ok := m.posts[id]; ok {
+			result[id] = post
+		}
+	}
+	return result, nil
+}
+
+func (m memoryPosts) Text(ctx context.Context, q search.Query, p Page) ([]Scored[types.Post], error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	docs := values(m.posts, postId, func(post types.Post) bool {
+		return live(post.Deletion) &&
+			(q.Author == "" || post.Author == q.AuthorID) &&
+			(q.Board == "" || post.Board == q.BoardID) &&
+			(q.Type == nil || post.BodyType == *q.Type) &&
+			q.Votes.Contains(post.Votes)
+	})
+	return scoreText(docs, q, p, postId, postFields), nil
+}
+
+func (m memoryPosts) SoftDelete(ctx context.Context, board, id, by primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	post, ok := m.posts[id]
+	if !ok || post.Board != board || !live(post.Deletion) {
+		return ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	markDeleted(&post.Deletion, by, nil, now)
+	m.posts[id] = post
+
+	for commentId, comment := range m.comments {
+		if comment.Post == id && live(comment.Deletion) {
+			markDeleted(&comment.Deletion, by, &id, now)
+			m.comments[commentId] = comment
+		}
+	}
+	return nil
+}
+
+func (m memoryPosts) Restore(ctx context.Context, board, id primitive.ObjectID, since time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	post, ok := m.posts[id]
+	if !ok || post.Board != board || !restorableSince(post.Deletion, since) {
+		return ErrNotFound
+	}
+	post.Deletion = types.Deletion{}
+	m.posts[id] = post
+
+	m.restoreWith(id)
+	return nil
+}
+
+func (m memoryPosts) Trash(ctx context.Context, board primitive.ObjectID, since time.Time, limit int64) ([]types.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	posts := values(m.posts, postId, func(p types.Post) bool {
+		return p.Board == board && restorableSince(p.Deletion, since)
+	})
+	sort.SliceStable(posts, func(i, j int) bool { return posts[i].DeletedAt.After(*posts[j].DeletedAt) })
+
+	if int64(len(posts)) > limit {
+		posts = posts[:limit]
+	}
+	return posts, nil
+}
+
+func (m memoryPosts) Purge(ctx context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.cascadePosts(func(p types.Post) bool {
+		return p.DeletedAt != nil && p.DeletedAt.Before(before)
+	}), nil
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/types"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryRevisions struct{ *memoryDB }
+
+func revisionId(r types.Revision) primitive.ObjectID { return r.ID }
+
+func revisionOf(post primitive.ObjectID, comment *primitive.ObjectID) func(types.Revision) bool {
+	return func(r types.Revision) bool {
+		if r.Post != post || (r.Comment == nil) != (comment == nil) {
+			return false
+		}
+		return comment == nil || *r.Comment == *comment
+	}
+}
+
+func (m memoryRevisions) Create(ctx context.Context, revision types.Revision) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	revision.ID = newId(revision.ID)
+	if _, ok := m.revisions[revision.ID]; ok {
+		return ErrDuplicate
+	}
+	m.revisions[revision.ID] = revision
+	return nil
+}
+
+func (m memoryRevisions) List(ctx context.Context, post primitive.ObjectID, comment *primitive.ObjectID, p Page) ([]types.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matching := values(m.revisions, revisionId, revisionOf(post, comment))
+	return paginate(matching, Page{Limit: p.Limit, After: p.After}, func(r types.Revision) (float64, primitive.ObjectID) {
+		return 0, r.ID
+	}), nil
+}
+
+func (m memoryRevisions) First(ctx context.Context, post primitive.ObjectID, comment *primitive.ObjectID) (types.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matching := values(m.revisions, revisionId, revisionOf(post, comment))
+	if len(matching) == 0 {
+		return types.Revision{}, ErrNotFound
+	}
+	return matching[0], nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/types"
+	"slices"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryRoles struct{ *memoryDB }
+
+func (m memoryRoles) Has(ctx context.Context, user primitive.ObjectID, role types.RoleName) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.roles[roleKey{user: user, role: role}]
+	return ok, nil
+}
+
+func (m memoryRoles) Of(ctx context.Context, user primitive.ObjectID) ([]types.Role, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	roles := []types.Role{}
+	for key, role := range m.roles {
+		if key.user == user {
+			roles = append(roles, role)
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].GrantedAt.Before(roles[j].GrantedAt) })
+	return roles, nil
+}
+
+func (m memoryRoles) record(user primitive.ObjectID, role types.RoleName, action string, by primitive.ObjectID) {
+	m.audit = append(m.audit, types.RoleAudit{
+		ID:     primitive.NewObjectID(),
+		User:   user,
+		Role:   role,
+		Action: action,
+		By:     by,
+		At:     time.Now(),
+	})
+}
+
+func (m memoryRoles) Grant(ctx context.Context, user primitive.ObjectID, role types.RoleName, by primitive.ObjectID) error {
+	if !role.Valid() {
+		return types.ErrUnknownRole
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := roleKey{user: user, role: role}
+	if _, ok := m.roles[key]; ok {
+		return nil
+	}
+
+	m.roles[key] = types.Role{
+		ID:        primitive.NewObjectID(),
+		User:      user,
+		Role:      role,
+		GrantedBy: by,
+		GrantedAt: time.Now(),
+	}
+	m.record(user, role, "grant", by)
+	return nil
+}
+
+func (m memoryRoles) Revoke(ctx context.Context, user primitive.ObjectID, role types.RoleName, by primitive.ObjectID) error {
+	if !role.Valid() {
+		return types.ErrUnknownRole
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := roleKey{user: user, role: role}
+	if _, ok := m.roles[key]; !ok {
+		return ErrNotFound
+	}
+
+	delete(m.roles, key)
+	m.record(user, role, "revoke", by)
+	return nil
+}
+
+func (m memoryRoles) Audit(ctx context.Context) ([]types.RoleAudit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := slices.Clone(m.audit)
+	slices.Reverse(entries)
+	if entries == nil {
+		entries = []types.RoleAudit{}
+	}
+	return entries, nil
+}
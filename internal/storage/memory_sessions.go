@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memorySessions struct{ *memoryDB }
+
+func (m memorySessions) Create(ctx context.Context, session types.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session.ID = newId(session.ID)
+	if _, ok := m.sessions[session.ID]; ok {
+		return ErrDuplicate
+	}
+	m.sessions[session.ID] = session
+	return nil
+}
+
+func (m memorySessions) Get(ctx context.Context, id primitive.ObjectID) (types.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return types.Session{}, ErrNotFound
+	}
+	return session, nil
+}
+
+func (m memorySessions) Rotate(ctx context.Context, hash, next string, expires time.Time) (types.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, session := range m.sessions {
+		if session.RefreshHash != hash || !session.RefreshExpires.After(now) {
+			continue
+		}
+		session.RefreshHash = next
+		session.RefreshExpires = expires
+		m.sessions[id] = session
+		return session, nil
+	}
+	return types.Session{}, ErrNotFound
+}
+
+func (m memorySessions) Delete(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}
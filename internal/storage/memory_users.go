@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/search"
+	"redoot/internal/types"
+	"slices"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryUsers struct{ *memoryDB }
+
+func userId(u types.User) primitive.ObjectID { return u.ID }
+
+// userFields mirrors the weights of the users text index.
+func userFields(u types.User) []search.Field {
+	return []search.Field{{Text: u.Name, Weight: 10}, {Text: u.Bio, Weight: 1}}
+}
+
+func (m memoryUsers) Create(ctx context.Context, user types.User) (primitive.ObjectID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user.ID = newId(user.ID)
+	if _, ok := m.users[user.ID]; ok {
+		return primitive.NilObjectID, ErrDuplicate
+	}
+	created(&user.Timestamps)
+	m.users[user.ID] = user
+	return user.ID, nil
+}
+
+func (m memoryUsers) Get(ctx context.Context, id primitive.ObjectID) (types.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return types.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (m memoryUsers) ByName(ctx context.Context, name string) (types.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range values(m.users, userId, nil) {
+		if user.Name == name {
+			return user, nil
+		}
+	}
+	return types.User{}, ErrNotFound
+}
+
+func (m memoryUsers) List(ctx context.Context, p Page) ([]types.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return paginate(values(m.users, userId, nil), p, func(u types.User) (float64, primitive.ObjectID) {
+		return 0, u.ID
+	}), nil
+}
+
+func (m memoryUsers) Lookup(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]types.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := map[primitive.ObjectID]types.User{}
+	for _, id := range ids {
+		if user, ok := m.users[id]; ok {
+			result[id] = user
+		}
+	}
+	return result, nil
+}
+
+func (m memoryUsers) Update(ctx context.Context, id primitive.ObjectID, user types.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	user.ID = id
+	user.Timestamps = old.Timestamps
+	if user == old {
+		return ErrNotModified
+	}
+	user.UpdatedAt = stamp()
+	m.users[id] = user
+	return nil
+}
+
+func (m memoryUsers) Delete(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.users, id)
+
+	for postId, post := range m.posts {
+		if post.Author == id {
+			post.Author = primitive.NilObjectID
+			m.posts[postId] = post
+		}
+	}
+	for commentId, comment := range m.comments {
+		if comment.Author == id {
+			comment.Author = primitive.NilObjectID
+			m.comments[commentId] = comment
+		}
+	}
+
+	for boardId, board := range m.boards {
+		board.Moderators = slices.DeleteFunc(slices.Clone(board.Moderators), func(m primitive.ObjectID) bool { return m == id })
+		if board.Owner == id {
+			board.Owner = primitive.NilObjectID
+			if len(board.Moderators) > 0 {
+				board.Owner = board.Moderators[0]
+			}
+		}
+		m.boards[boardId] = board
+	}
+
+	for sessionId, session := range m.sessions {
+		if session.User == id {
+			delete(m.sessions, sessionId)
+		}
+	}
+	for key := range m.roles {
+		if key.user == id {
+			delete(m.roles, key)
+		}
+	}
+
+	for key := range m.subscriptions {
+		if key.user == id {
+			delete(m.subscriptions, key)
+		}
+	}
+	m.dropNotifications(func(n types.Notification) bool { return n.User == id })
+	for notificationId, notification := range m.notifications {
+		if notification.Actor == id {
+			notification.Actor = primitive.NilObjectID
+			m.notifications[notificationId] = notification
+		}
+	}
+	return nil
+}
+
+func (m memoryUsers) Search(ctx context.Context, field, value string) ([]types.User, error) {
+	pattern, err := searchPattern(value)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return values(m.users, userId, func(u types.User) bool {
+		return matches(u, field, pattern)
+	}), nil
+}
+
+func (m memoryUsers) Text(ctx context.Context, q search.Query, p Page) ([]Scored[types.User], error) {
+	if q.ContentOnly() {
+		return []Scored[types.User]{}, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return scoreText(values(m.users, userId, nil), q, p, userId, userFields), nil
+}
+
+func (m memoryUsers) Popular(ctx context.Context, limit int64) ([]types.PopularUser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	votes := map[primitive.ObjectID]int{}
+	for _, post := range m.posts {
+		votes[post.Author] += post.Votes
+	}
+	for _, comment := range m.comments {
+		votes[comment.Author] += comment.Votes
+	}
+
+	answers := []types.PopularUser{}
+	for _, user := range values(m.users, userId, nil) {
+		answers = append(answers, types.PopularUser{Name: user.Name, Votes: votes[user.ID]})
+	}
+	sort.SliceStable(answers, func(i, j int) bool { return answers[i].Votes > answers[j].Votes })
+
+	if int64(len(answers)) > limit {
+		answers = answers[:limit]
+	}
+	return answers, nil
+}
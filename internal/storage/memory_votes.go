@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryVotes struct{ *memoryDB }
+
+func (m memoryVotes) Cast(ctx context.Context, user, target primitive.ObjectID, kind types.VoteTarget, direction int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var score *int
+	var ups, downs *int
+	var contro *float64
+
+	post, isPost := m.posts[target]
+	comment, isComment := m.comments[target]
+	switch {
+	case kind == types.VotePost && isPost:
+		score, ups, downs, contro = &post.Votes, &post.Ups, &post.Downs, &post.Controversy
+	case kind == types.VoteComment && isComment:
+		score, ups, downs, contro = &comment.Votes, &comment.Ups, &comment.Downs, &comment.Controversy
+	default:
+		return 0, ErrNotFound
+	}
+
+	key := voteKey{user: user, target: target}
+	previous := m.votes[key]
+	if direction == 0 {
+		delete(m.votes, key)
+	} else {
+		m.votes[key] = types.Vote{
+			ID:         newId(previous.ID),
+			User:       user,
+			Target:     target,
+			TargetType: kind,
+			Direction:  direction,
+		}
+	}
+
+	oldUps, oldDowns := counts(previous.Direction)
+	newUps, newDowns := counts(direction)
+	*score += direction - previous.Direction
+	*ups += newUps - oldUps
+	*downs += newDowns - oldDowns
+	*contro = controversy(*ups, *downs)
+
+	if isPost && kind == types.VotePost {
+		post.Hot = hot(post.Votes, post.ID)
+		post.Rising = rising(post.Votes, post.Comments, post.ID, time.Now())
+		m.posts[target] = post
+	} else {
+		m.comments[target] = comment
+	}
+	return *score, nil
+}
+
+func (m memoryVotes) Of(ctx context.Context, user primitive.ObjectID, targets []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := map[primitive.ObjectID]int{}
+	for _, target := range targets {
+		if vote, ok := m.votes[voteKey{user: user, target: target}]; ok {
+			result[target] = vote.Direction
+		}
+	}
+	return result, nil
+}
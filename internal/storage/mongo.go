@@ -0,0 +1,376 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/search"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoDB struct {
+	client    *mongo.Client
+	users     *mongo.Collection
+	boards    *mongo.Collection
+	posts     *mongo.Collection
+	comments  *mongo.Collection
+	votes     *mongo.Collection
+	sessions  *mongo.Collection
+	roles     *mongo.Collection
+	roleAudit *mongo.Collection
+
+	notifications *mongo.Collection
+	subscriptions *mongo.Collection
+	revisions     *mongo.Collection
+}
+
+// NewMongo keeps everything in the collections of db, deletes spanning
+// several collections run in transactions so mongo has to be a replica set.
+func NewMongo(client *mongo.Client, db *mongo.Database) Stores {
+	m := &mongoDB{
+		client:    client,
+		users:     db.Collection("users"),
+		boards:    db.Collection("boards"),
+		posts:     db.Collection("posts"),
+		comments:  db.Collection("comments"),
+		votes:     db.Collection("votes"),
+		sessions:  db.Collection("sessions"),
+		roles:     db.Collection("roles"),
+		roleAudit: db.Collection("role_audit"),
+
+		notifications: db.Collection("notifications"),
+		subscriptions: db.Collection("subscriptions"),
+		revisions:     db.Collection("revisions"),
+	}
+
+	return Stores{
+		Users:    mongoUsers{m},
+		Boards:   mongoBoards{m},
+		Posts:    mongoPosts{m},
+		Comments: mongoComments{m},
+		Votes:    mongoVotes{m},
+		Sessions: mongoSessions{m},
+		Roles:    mongoRoles{m},
+		Backup:   mongoBackup{m},
+
+		Notifications: mongoNotifications{m},
+		Subscriptions: mongoSubscriptions{m},
+		Revisions:     mongoRevisions{m},
+	}
+}
+
+func notFound(err error) error {
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	return err
+}
+
+// withTransaction runs fn inside a transaction, every operation in fn has to
+// use the context it's given.
+func (m *mongoDB) withTransaction(ctx context.Context, fn func(ctx mongo.SessionContext) error) error {
+	session, err := m.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+func findOne[T any](ctx context.Context, coll *mongo.Collection, filter bson.M) (T, error) {
+	var result T
+	err := coll.FindOne(ctx, filter).Decode(&result)
+	return result, notFound(err)
+}
+
+func findAll[T any](ctx context.Context, coll *mongo.Collection, filter any, opts ...*options.FindOptions) ([]T, error) {
+	cursor, err := coll.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []T{}
+	err = cursor.All(ctx, &results)
+	return results, err
+}
+
+func insert(ctx context.Context, coll *mongo.Collection, doc any) (primitive.ObjectID, error) {
+	result, err := coll.InsertOne(ctx, doc)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// update sets the fields of doc on the live document with the id but its
+// timestamps and the kept fields, those only change through their own
+// atomic updates so a stale doc can't undo votes or comments cast meanwhile.
+// The omitempty fields doc leaves out are unset, an image post turned into
+// a text post loses its image. updatedAt is only stamped when something else
+// changed.
+func update(ctx context.Context, coll *mongo.Collection, id primitive.ObjectID, doc any, kept ...string) error {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var fields bson.M
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+	unset := omitted(reflect.TypeOf(doc), fields)
+
+	// deletions and restores own the rest
+	kept = append([]string{"_id", "createdAt", "updatedAt", "deletedAt", "deletedBy", "deletedWith", "purged"}, kept...)
+	for _, field := range kept {
+		delete(fields, field)
+		delete(unset, field)
+	}
+
+	changes := bson.M{"$set": fields}
+	if len(unset) > 0 {
+		changes["$unset"] = unset
+	}
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": id, "deletedAt": nil}, changes)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	if result.ModifiedCount == 0 {
+		return ErrNotModified
+	}
+
+	_, err = coll.UpdateByID(ctx, id, bson.M{"$set": bson.M{"updatedAt": stamp()}})
+	return err
+}
+
+// omitted lists the omitempty fields of the struct type missing from the
+// marshalled fields, inlined structs included.
+func omitted(t reflect.Type, fields bson.M) bson.M {
+	unset := bson.M{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("bson"), ",")
+		switch {
+		case name == "-":
+		case strings.Contains(opts, "inline"):
+			for field := range omitted(f.Type, fields) {
+				unset[field] = ""
+			}
+		case strings.Contains(opts, "omitempty"):
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			if _, ok := fields[name]; !ok {
+				unset[name] = ""
+			}
+		}
+	}
+	return unset
+}
+
+// BackfillTimestamps stamps the documents written before they had
+// timestamps with the creation time in their id, updatedAt falls back to
+// the last edit.
+func BackfillTimestamps(ctx context.Context, db *mongo.Database) error {
+	created := bson.M{"$toDate": "$_id"}
+	for _, coll := range []string{"users", "boards", "posts", "comments"} {
+		_, err := db.Collection(coll).UpdateMany(ctx, bson.M{"createdAt": bson.M{"$exists": false}}, mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{
+				"createdAt": created,
+				"updatedAt": bson.M{"$ifNull": bson.A{"$updatedAt", "$editedAt", created}},
+			}}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// regexSearch matches the field against the value as a case insensitive regex.
+func regexSearch[T any](ctx context.Context, coll *mongo.Collection, field, value string, live bool) ([]T, error) {
+	match := bson.D{{Key: field, Value: primitive.Regex{Pattern: value, Options: "i"}}}
+	if live {
+		match = append(match, bson.E{Key: "deletedAt", Value: nil})
+	}
+
+	cursor, err := coll.Aggregate(ctx, mongo.Pipeline{{{Key: "$match", Value: match}}})
+	if err != nil {
+		return nil, err
+	}
+
+	results := []T{}
+	err = cursor.All(ctx, &results)
+	return results, err
+}
+
+// queryFilter compiles the query into a filter on the live documents, the
+// text part goes to the text index of the collection and fields are the
+// ones it covers.
+func queryFilter(q search.Query, fields ...string) bson.M {
+	filter := bson.M{"deletedAt": nil}
+	if q.HasText() {
+		filter["$text"] = bson.M{"$search": q.TextSearch()}
+	}
+	// $text is happy with any of the words and with the phrases alone, more
+	// than one clause has to be checked against the fields
+	clauses := q.Clauses()
+	if len(clauses) > 1 || (len(clauses) == 1 && len(q.Phrases) > 0) {
+		all := bson.A{}
+		for _, clause := range clauses {
+			either := bson.A{}
+			for _, term := range clause {
+				for _, field := range fields {
+					either = append(either, bson.M{field: primitive.Regex{Pattern: search.WordPattern(term), Options: "i"}})
+				}
+			}
+			all = append(all, bson.M{"$or": either})
+		}
+		filter["$and"] = all
+	}
+	if q.Author != "" {
+		filter["author"] = q.AuthorID
+	}
+	if q.Board != "" {
+		filter["board"] = q.BoardID
+	}
+	if q.Type != nil {
+		filter["bodyType"] = *q.Type
+	}
+	if !q.Votes.Empty() {
+		votes := bson.M{}
+		if q.Votes.Min != nil {
+			votes["$gte"] = *q.Votes.Min
+		}
+		if q.Votes.Max != nil {
+			votes["$lte"] = *q.Votes.Max
+		}
+		filter["votes"] = votes
+	}
+
+	// ids start with the creation time, so they double as a date filter
+	created := bson.M{}
+	if q.Since != nil {
+		created["$gte"] = primitive.NewObjectIDFromTimestamp(*q.Since)
+	}
+	if q.Until != nil {
+		created["$lt"] = primitive.NewObjectIDFromTimestamp(*q.Until)
+	}
+	if len(created) > 0 {
+		filter["_id"] = created
+	}
+	return filter
+}
+
+// textSearch pages through the documents matching the filter compiled from
+// the query by their text score, without any text they all score zero and
+// come newest first.
+func textSearch[T any](ctx context.Context, coll *mongo.Collection, q search.Query, filter bson.M, p Page) ([]Scored[T], error) {
+	results := []Scored[T]{}
+
+	var score any = 0
+	if q.HasText() {
+		score = bson.M{"$meta": "textScore"}
+	}
+
+	p.Sort = SortScore
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$addFields", Value: bson.M{"score": score}}},
+		{{Key: "$match", Value: pageFilter(p, bson.M{})}},
+		{{Key: "$sort", Value: pageSort(p)}},
+		{{Key: "$limit", Value: p.Limit + 1}},
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc T
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		score, _ := cursor.Current.Lookup("score").DoubleOK()
+		results = append(results, Scored[T]{Doc: doc, Score: score})
+	}
+	return results, cursor.Err()
+}
+
+func distinctIds(ctx context.Context, coll *mongo.Collection, filter bson.M) ([]primitive.ObjectID, error) {
+	values, err := coll.Distinct(ctx, "_id", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// pageFilter narrows the base filter down to the documents after the cursor
+// created since p.Since.
+func pageFilter(p Page, base bson.M) bson.M {
+	if p.After == nil && p.Since == nil {
+		return base
+	}
+
+	filter := bson.M{}
+	for k, v := range base {
+		filter[k] = v
+	}
+
+	ids := bson.M{}
+	if p.Since != nil {
+		ids["$gte"] = primitive.NewObjectIDFromTimestamp(*p.Since)
+	}
+	if p.After != nil && p.Sort == "" {
+		ids["$lt"] = p.After.ID
+	}
+	if len(ids) > 0 {
+		filter["_id"] = ids
+	}
+	if p.After == nil || p.Sort == "" {
+		return filter
+	}
+
+	after := bson.A{
+		bson.M{p.Sort: bson.M{"$lt": p.After.Value}},
+		bson.M{p.Sort: p.After.Value, "_id": bson.M{"$lt": p.After.ID}},
+	}
+	if or, ok := base["$or"]; ok {
+		delete(filter, "$or")
+		filter["$and"] = bson.A{bson.M{"$or": or}, bson.M{"$or": after}}
+	} else {
+		filter["$or"] = after
+	}
+	return filter
+}
+
+func pageSort(p Page) bson.D {
+	if p.Sort != "" {
+		return bson.D{{Key: p.Sort, Value: -1}, {Key: "_id", Value: -1}}
+	}
+	return bson.D{{Key: "_id", Value: -1}}
+}
+
+// pageOptions asks for one document more than the limit.
+func pageOptions(p Page) *options.FindOptions {
+	return options.Find().SetSort(pageSort(p)).SetLimit(p.Limit + 1)
+}
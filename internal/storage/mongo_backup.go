@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoBackup struct{ *mongoDB }
+
+func (m mongoBackup) Export(ctx context.Context) (Dump, error) {
+	var dump Dump
+	var err error
+
+	if dump.Users, err = findAll[types.User](ctx, m.users, bson.M{}); err != nil {
+		return dump, err
+	}
+	if dump.Boards, err = findAll[types.Board](ctx, m.boards, bson.M{}); err != nil {
+		return dump, err
+	}
+	if dump.Posts, err = findAll[types.Post](ctx, m.posts, bson.M{}); err != nil {
+		return dump, err
+	}
+	dump.Comments, err = findAll[types.Comment](ctx, m.comments, bson.M{})
+	return dump, err
+}
+
+func insertMany[T any](ctx context.Context, coll *mongo.Collection, docs []T) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	inters := make([]interface{}, len(docs))
+	for i, d := range docs {
+		inters[i] = d
+	}
+	_, err := coll.InsertMany(ctx, inters)
+	return err
+}
+
+func (m mongoBackup) Import(ctx context.Context, dump Dump) error {
+	for i, user := range dump.Users {
+		dump.Users[i].ID = newId(user.ID)
+		backdate(&dump.Users[i].Timestamps, dump.Users[i].ID)
+	}
+	if err := insertMany(ctx, m.users, dump.Users); err != nil {
+		return err
+	}
+	for i, board := range dump.Boards {
+		dump.Boards[i].ID = newId(board.ID)
+		backdate(&dump.Boards[i].Timestamps, dump.Boards[i].ID)
+	}
+	if err := insertMany(ctx, m.boards, dump.Boards); err != nil {
+		return err
+	}
+	for i, post := range dump.Posts {
+		dump.Posts[i].ID = newId(post.ID)
+		dump.Posts[i].Hot = hot(post.Votes, dump.Posts[i].ID)
+		backdate(&dump.Posts[i].Timestamps, dump.Posts[i].ID)
+	}
+	if err := insertMany(ctx, m.posts, dump.Posts); err != nil {
+		return err
+	}
+	for i, comment := range dump.Comments {
+		dump.Comments[i].ID = newId(comment.ID)
+		backdate(&dump.Comments[i].Timestamps, dump.Comments[i].ID)
+	}
+	return insertMany(ctx, m.comments, dump.Comments)
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"redoot/internal/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const mediaBucket = "media"
+
+type gridBlobs struct {
+	db *mongo.Database
+}
+
+// NewGridFS keeps blobs in the media GridFS bucket of db. Files are named by
+// the media id and carry its description as metadata, two uploads racing
+// each other leave identical copies behind which is harmless.
+func NewGridFS(db *mongo.Database) BlobStore {
+	return gridBlobs{db}
+}
+
+// bucket opens the bucket with the deadline of ctx, the driver's GridFS
+// doesn't take contexts for uploads and downloads.
+func (g gridBlobs) bucket(ctx context.Context) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(g.db, options.GridFSBucket().SetName(mediaBucket))
+	if err != nil {
+		return nil, err
+	}
+
+	// no deadline is the zero time which turns them off
+	deadline, _ := ctx.Deadline()
+	if err := bucket.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	return bucket, bucket.SetWriteDeadline(deadline)
+}
+
+func (g gridBlobs) Put(ctx context.Context, media types.Media, data []byte) error {
+	_, err := g.Stat(ctx, media.ID)
+	if err == nil {
+		return nil
+	} else if err != ErrNotFound {
+		return err
+	}
+
+	bucket, err := g.bucket(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = bucket.UploadFromStream(media.ID, bytes.NewReader(data), options.GridFSUpload().SetMetadata(media))
+	return err
+}
+
+func (g gridBlobs) Get(ctx context.Context, id string) (types.Media, []byte, error) {
+	bucket, err := g.bucket(ctx)
+	if err != nil {
+		return types.Media{}, nil, err
+	}
+
+	stream, err := bucket.OpenDownloadStreamByName(id)
+	if err == gridfs.ErrFileNotFound {
+		return types.Media{}, nil, ErrNotFound
+	} else if err != nil {
+		return types.Media{}, nil, err
+	}
+	defer stream.Close()
+
+	var media types.Media
+	err = bson.Unmarshal(stream.GetFile().Metadata, &media)
+	if err != nil {
+		return types.Media{}, nil, err
+	}
+
+	data, err := io.ReadAll(stream)
+	return media, data, err
+}
+
+func (g gridBlobs) Stat(ctx context.Context, id string) (types.Media, error) {
+	var file struct {
+		Metadata types.Media `bson:"metadata"`
+	}
+
+	files := g.db.Collection(mediaBucket + ".files")
+	err := files.FindOne(ctx, bson.M{"filename": id}).Decode(&file)
+	return file.Metadata, notFound(err)
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/search"
+	"redoot/internal/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoBoards struct{ *mongoDB }
+
+func deletion(by primitive.ObjectID, with *primitive.ObjectID) bson.M {
+	set := bson.M{
+		"deletedAt": time.Now().UTC(),
+		"deletedBy": by,
+	}
+	if with != nil {
+		set["deletedWith"] = with
+	}
+	return bson.M{"$set": set}
+}
+
+var restoration = bson.M{"$unset": bson.M{
+	"deletedAt":   "",
+	"deletedBy":   "",
+	"deletedWith": "",
+}}
+
+// restorable matches documents deleted on their own after since, documents
+// deleted with their board or post come back with it.
+func restorable(filter bson.M, since time.Time) bson.M {
+	filter["deletedAt"] = bson.M{"$gte": since}
+	filter["deletedWith"] = nil
+	filter["purged"] = nil
+	return filter
+}
+
+// restore brings back the document and whatever was deleted together with it.
+func restore(ctx context.Context, coll *mongo.Collection, filter bson.M, id primitive.ObjectID, since time.Time, dependents ...*mongo.Collection) error {
+	result, err := coll.UpdateOne(ctx, restorable(filter, since), restoration)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	for _, dependent := range dependents {
+		_, err := dependent.UpdateMany(ctx, bson.M{"deletedWith": id}, restoration)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cascadePosts deletes the posts matching the filter together with their
+// comments and every vote cast on either, returns the amount of posts deleted.
+func (m *mongoDB) cascadePosts(ctx context.Context, filter bson.M) (int, error) {
+	postIds, err := distinctIds(ctx, m.posts, filter)
+	if err != nil || len(postIds) == 0 {
+		return 0, err
+	}
+
+	commentIds, err := distinctIds(ctx, m.comments, bson.M{"post": bson.M{"$in": postIds}})
+	if err != nil {
+		return 0, err
+	}
+
+	targets := append(postIds, commentIds...)
+	_, err = m.votes.DeleteMany(ctx, bson.M{"target": bson.M{"$in": targets}})
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = m.comments.DeleteMany(ctx, bson.M{"post": bson.M{"$in": postIds}})
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = m.notifications.DeleteMany(ctx, bson.M{"post": bson.M{"$in": postIds}})
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = m.revisions.DeleteMany(ctx, bson.M{"post": bson.M{"$in": postIds}})
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := m.posts.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": postIds}})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.DeletedCount), nil
+}
+
+func (m mongoBoards) Create(ctx context.Context, board types.Board) (primitive.ObjectID, error) {
+	created(&board.Timestamps)
+	return insert(ctx, m.boards, board)
+}
+
+func (m mongoBoards) Get(ctx context.Context, id primitive.ObjectID) (types.Board, error) {
+	return findOne[types.Board](ctx, m.boards, bson.M{"_id": id, "deletedAt": nil})
+}
+
+func (m mongoBoards) GetAny(ctx context.Context, id primitive.ObjectID) (types.Board, error) {
+	return findOne[types.Board](ctx, m.boards, bson.M{"_id": id})
+}
+
+func (m mongoBoards) List(ctx context.Context, p Page) ([]types.Board, error) {
+	return findAll[types.Board](ctx, m.boards, pageFilter(p, bson.M{"deletedAt": nil}), pageOptions(p))
+}
+
+func (m mongoBoards) Update(ctx context.Context, id primitive.ObjectID, board types.Board) error {
+	return update(ctx, m.boards, id, board)
+}
+
+func (m mongoBoards) Search(ctx context.Context, field, value string) ([]types.Board, error) {
+	return regexSearch[types.Board](ctx, m.boards, field, value, true)
+}
+
+func (m mongoBoards) Text(ctx context.Context, q search.Query, p Page) ([]Scored[types.Board], error) {
+	return textSearch[types.Board](ctx, m.boards, q, queryFilter(q, "name", "bio", "rules"), p)
+}
+
+func (m mongoBoards) SoftDelete(ctx context.Context, id, by primitive.ObjectID) error {
+	return m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+		result, err := m.boards.UpdateOne(ctx, bson.M{"_id": id, "deletedAt": nil}, deletion(by, nil))
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrNotFound
+		}
+
+		postIds, err := distinctIds(ctx, m.posts, bson.M{"board": id})
+		if err != nil {
+			return err
+		}
+
+		_, err = m.posts.UpdateMany(ctx, bson.M{"board": id, "deletedAt": nil}, deletion(by, &id))
+		if err != nil {
+			return err
+		}
+		_, err = m.comments.UpdateMany(ctx, bson.M{"post": bson.M{"$in": postIds}, "deletedAt": nil}, deletion(by, &id))
+		return err
+	})
+}
+
+func (m mongoBoards) Restore(ctx context.Context, id primitive.ObjectID, since time.Time) error {
+	return m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+		return restore(ctx, m.boards, bson.M{"_id": id}, id, since, m.posts, m.comments)
+	})
+}
+
+func (m mongoBoards) Purge(ctx context.Context, before time.Time) (int, error) {
+	ids, err := distinctIds(ctx, m.boards, bson.M{"deletedAt": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		err := m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+			if _, err := m.cascadePosts(ctx, bson.M{"board": id}); err != nil {
+				return err
+			}
+			if _, err := m.notifications.DeleteMany(ctx, bson.M{"board": id}); err != nil {
+				return err
+			}
+			if _, err := m.subscriptions.DeleteMany(ctx, bson.M{"board": id}); err != nil {
+				return err
+			}
+			_, err := m.boards.DeleteOne(ctx, bson.M{"_id": id})
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/search"
+	"redoot/internal/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoComments struct{ *mongoDB }
+
+func (m mongoComments) Create(ctx context.Context, comment types.Comment) (primitive.ObjectID, error) {
+	created(&comment.Timestamps)
+
+	var id primitive.ObjectID
+	err := m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+		var err error
+		id, err = insert(ctx, m.comments, comment)
+		if err != nil {
+			return err
+		}
+
+		commented := mongo.Pipeline{
+			{{Key: "$set", Value: bson.D{{Key: "comments", Value: bson.D{{Key: "$add", Value: bson.A{
+				bson.D{{Key: "$ifNull", Value: bson.A{"$comments", 0}}},
+				1,
+			}}}}}}},
+			rankStage(),
+		}
+		_, err = m.posts.UpdateOne(ctx, bson.M{"_id": comment.Post}, commented)
+		return err
+	})
+	return id, err
+}
+
+func (m mongoComments) Get(ctx context.Context, post, id primitive.ObjectID) (types.Comment, error) {
+	return findOne[types.Comment](ctx, m.comments, bson.M{"_id": id, "post": post, "deletedAt": nil})
+}
+
+func (m mongoComments) GetAny(ctx context.Context, post, id primitive.ObjectID) (types.Comment, error) {
+	return findOne[types.Comment](ctx, m.comments, bson.M{"_id": id, "post": post})
+}
+
+func (m mongoComments) List(ctx context.Context, post primitive.ObjectID, p Page) ([]types.Comment, error) {
+	filter := bson.M{"post": post, "deletedAt": nil}
+	return findAll[types.Comment](ctx, m.comments, pageFilter(p, filter), pageOptions(p))
+}
+
+func (m mongoComments) Thread(ctx context.Context, post primitive.ObjectID, parent *primitive.ObjectID, p Page) ([]types.Comment, error) {
+	filter := bson.M{"post": post, "parent": nil}
+	if parent != nil {
+		filter["parent"] = parent
+	}
+	return findAll[types.Comment](ctx, m.comments, pageFilter(p, filter), pageOptions(p))
+}
+
+func (m mongoComments) Replies(ctx context.Context, parents []primitive.ObjectID, p Page) ([]types.Comment, error) {
+	cursor, err := m.comments.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"parent": bson.M{"$in": parents}}}},
+		{{Key: "$sort", Value: pageSort(p)}},
+		{{Key: "$group", Value: bson.M{"_id": "$parent", "replies": bson.M{"$push": "$$ROOT"}}}},
+		{{Key: "$project", Value: bson.M{"replies": bson.M{"$slice": bson.A{"$replies", p.Limit + 1}}}}},
+		{{Key: "$unwind", Value: "$replies"}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$replies"}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	replies := []types.Comment{}
+	err = cursor.All(ctx, &replies)
+	return replies, err
+}
+
+func (m mongoComments) Answered(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	parents, err := m.comments.Distinct(ctx, "parent", bson.M{"parent": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+
+	answered := map[primitive.ObjectID]bool{}
+	for _, parent := range parents {
+		if id, ok := parent.(primitive.ObjectID); ok {
+			answered[id] = true
+		}
+	}
+	return answered, nil
+}
+
+// commentCounters are kept by votes, never by edits.
+var commentCounters = []string{"votes", "ups", "downs", "controversy"}
+
+func (m mongoComments) Update(ctx context.Context, id primitive.ObjectID, comment types.Comment) error {
+	// a reply stays a reply, like the memory store keeps the parent
+	return update(ctx, m.comments, id, comment, append([]string{"parent"}, commentCounters...)...)
+}
+
+func (m mongoComments) SoftDelete(ctx context.Context, post, id, by primitive.ObjectID) error {
+	result, err := m.comments.UpdateOne(ctx, bson.M{"_id": id, "post": post, "deletedAt": nil}, deletion(by, nil))
+	if err == nil && result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (m mongoComments) Restore(ctx context.Context, post, id primitive.ObjectID, since time.Time) error {
+	return restore(ctx, m.comments, bson.M{"_id": id, "post": post}, id, since)
+}
+
+func (m mongoComments) Text(ctx context.Context, q search.Query, p Page) ([]Scored[types.Comment], error) {
+	filter := queryFilter(q, "body")
+
+	// comments only know their post, the board goes through it
+	if q.Board != "" {
+		delete(filter, "board")
+		postIds, err := distinctIds(ctx, m.posts, bson.M{"board": q.BoardID})
+		if err != nil {
+			return nil, err
+		}
+		filter["post"] = bson.M{"$in": postIds}
+	}
+	if !q.PostID.IsZero() {
+		filter["post"] = q.PostID
+	}
+
+	return textSearch[types.Comment](ctx, m.comments, q, filter, p)
+}
+
+func (m mongoComments) Trash(ctx context.Context, board primitive.ObjectID, since time.Time, limit int64) ([]types.Comment, error) {
+	postIds, err := distinctIds(ctx, m.posts, bson.M{"board": board, "deletedAt": nil})
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "deletedAt", Value: -1}}).SetLimit(limit)
+	filter := restorable(bson.M{"post": bson.M{"$in": postIds}}, since)
+	return findAll[types.Comment](ctx, m.comments, filter, opts)
+}
+
+func (m mongoComments) Purge(ctx context.Context, before time.Time) (int, error) {
+	ids, err := distinctIds(ctx, m.comments, bson.M{
+		"deletedAt": bson.M{"$lt": before},
+		"purged":    nil,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		err := m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+			return m.purge(ctx, id)
+		})
+		if err != nil && err != ErrNotFound {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// purge keeps comments with replies as a placeholder so the thread stays
+// intact, comments without replies are deleted with their votes. Revisions
+// go either way, they would keep the content around.
+func (m mongoComments) purge(ctx context.Context, id primitive.ObjectID) error {
+	replies, err := m.comments.CountDocuments(ctx, bson.M{"parent": id})
+	if err != nil {
+		return err
+	}
+
+	_, err = m.revisions.DeleteMany(ctx, bson.M{"comment": id})
+	if err != nil {
+		return err
+	}
+
+	if replies > 0 {
+		result, err := m.comments.UpdateByID(ctx, id, bson.M{"$set": bson.M{
+			"body":     DeletedPlaceholder,
+			"bodyHtml": DeletedPlaceholderHTML,
+			"author":   primitive.NilObjectID,
+			"purged":   true,
+		}})
+		if err == nil && result.MatchedCount == 0 {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	result, err := m.comments.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	_, err = m.votes.DeleteMany(ctx, bson.M{"target": id})
+	return err
+}
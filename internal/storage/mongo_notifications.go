@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoNotifications struct{ *mongoDB }
+
+func (m mongoNotifications) Create(ctx context.Context, notifications []types.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	docs := make([]any, len(notifications))
+	for i, notification := range notifications {
+		docs[i] = notification
+	}
+	_, err := m.notifications.InsertMany(ctx, docs)
+	return err
+}
+
+func (m mongoNotifications) List(ctx context.Context, user primitive.ObjectID, unread bool, kind types.NotificationType, p Page) ([]types.Notification, error) {
+	filter := bson.M{"user": user}
+	if unread {
+		filter["read"] = false
+	}
+	if kind != "" {
+		filter["type"] = kind
+	}
+
+	p.Sort = ""
+	return findAll[types.Notification](ctx, m.notifications, pageFilter(p, filter), pageOptions(p))
+}
+
+func (m mongoNotifications) Unread(ctx context.Context, user primitive.ObjectID) (map[types.NotificationType]int, error) {
+	cursor, err := m.notifications.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user": user, "read": false}}},
+		{{Key: "$group", Value: bson.M{"_id": "$type", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []struct {
+		Type  types.NotificationType `bson:"_id"`
+		Count int                    `bson:"count"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	counts := map[types.NotificationType]int{}
+	for _, group := range groups {
+		counts[group.Type] = group.Count
+	}
+	return counts, nil
+}
+
+func (m mongoNotifications) MarkRead(ctx context.Context, user, id primitive.ObjectID) error {
+	result, err := m.notifications.UpdateOne(ctx, bson.M{"_id": id, "user": user}, bson.M{"$set": bson.M{"read": true}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m mongoNotifications) MarkAllRead(ctx context.Context, user primitive.ObjectID) (int, error) {
+	result, err := m.notifications.UpdateMany(ctx, bson.M{"user": user, "read": false}, bson.M{"$set": bson.M{"read": true}})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.ModifiedCount), nil
+}
+
+type mongoSubscriptions struct{ *mongoDB }
+
+func (m mongoSubscriptions) Subscribe(ctx context.Context, user, board primitive.ObjectID) error {
+	filter := bson.M{"user": user, "board": board}
+	update := bson.M{"$setOnInsert": types.Subscription{
+		User:      user,
+		Board:     board,
+		CreatedAt: time.Now(),
+	}}
+
+	_, err := m.subscriptions.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (m mongoSubscriptions) Unsubscribe(ctx context.Context, user, board primitive.ObjectID) error {
+	result, err := m.subscriptions.DeleteOne(ctx, bson.M{"user": user, "board": board})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m mongoSubscriptions) Subscribers(ctx context.Context, board primitive.ObjectID) ([]primitive.ObjectID, error) {
+	values, err := m.subscriptions.Distinct(ctx, "user", bson.M{"board": board})
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]primitive.ObjectID, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(primitive.ObjectID); ok {
+			users = append(users, id)
+		}
+	}
+	return users, nil
+}
+
+func (m mongoSubscriptions) Of(ctx context.Context, user primitive.ObjectID) ([]types.Subscription, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}})
+	return findAll[types.Subscription](ctx, m.subscriptions, bson.M{"user": user}, opts)
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/search"
+	"redoot/internal/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoPosts struct{ *mongoDB }
+
+func (m mongoPosts) Create(ctx context.Context, post types.Post) (primitive.ObjectID, error) {
+	post.ID = newId(post.ID)
+	post.Hot = hot(post.Votes, post.ID)
+	created(&post.Timestamps)
+	return insert(ctx, m.posts, post)
+}
+
+func (m mongoPosts) Get(ctx context.Context, board, id primitive.ObjectID) (types.Post, error) {
+	return findOne[types.Post](ctx, m.posts, bson.M{"_id": id, "board": board, "deletedAt": nil})
+}
+
+func (m mongoPosts) GetAny(ctx context.Context, board, id primitive.ObjectID) (types.Post, error) {
+	return findOne[types.Post](ctx, m.posts, bson.M{"_id": id, "board": board})
+}
+
+func (m mongoPosts) List(ctx context.Context, board primitive.ObjectID, p Page) ([]types.Post, error) {
+	filter := bson.M{
+		"board":     board,
+		"deletedAt": nil,
+	}
+	return findAll[types.Post](ctx, m.posts, pageFilter(p, filter), pageOptions(p))
+}
+
+func (m mongoPosts) Popular(ctx context.Context, p Page) ([]types.NicePost, error) {
+	return m.nice(ctx, bson.M{"deletedAt": nil}, p)
+}
+
+func (m mongoPosts) Feed(ctx context.Context, boards []primitive.ObjectID, p Page) ([]types.NicePost, error) {
+	return m.nice(ctx, bson.M{"board": bson.M{"$in": boards}, "deletedAt": nil}, p)
+}
+
+// nice pages through the posts matching the filter with author and board
+// names.
+func (m mongoPosts) nice(ctx context.Context, filter bson.M, p Page) ([]types.NicePost, error) {
+	matchStage := bson.D{{Key: "$match", Value: pageFilter(p, filter)}}
+	sortStage := bson.D{{Key: "$sort", Value: pageSort(p)}}
+	limitStage := bson.D{{Key: "$limit", Value: p.Limit + 1}}
+
+	lookupAuthorStage := bson.D{
+		{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "users"},
+			{Key: "localField", Value: "author"},
+			{Key: "foreignField", Value: "_id"},
+			{Key: "as", Value: "authorInfo"},
+		}},
+	}
+
+	lookupBoardStage := bson.D{
+		{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "boards"},
+			{Key: "localField", Value: "board"},
+			{Key: "foreignField", Value: "_id"},
+			{Key: "as", Value: "boardInfo"},
+		}},
+	}
+
+	projectFieldsStage := bson.D{
+		{Key: "$project", Value: bson.D{
+			{Key: "title", Value: 1},
+			{Key: "bodyType", Value: 1},
+			{Key: "bodyContent", Value: 1},
+			{Key: "bodyHtml", Value: 1},
+			{Key: "votes", Value: 1},
+			{Key: "controversy", Value: 1},
+			{Key: "hot", Value: 1},
+			{Key: "rising", Value: 1},
+			{Key: "comments", Value: 1},
+			{Key: "createdAt", Value: 1},
+			{Key: "author", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+				bson.D{{Key: "$arrayElemAt", Value: bson.A{"$authorInfo.name", 0}}},
+				DeletedPlaceholder,
+			}}}},
+			{Key: "board", Value: bson.D{{Key: "$arrayElemAt", Value: bson.A{"$boardInfo.name", 0}}}},
+		}},
+	}
+
+	pipeline := mongo.Pipeline{matchStage, sortStage, limitStage, lookupAuthorStage, lookupBoardStage, projectFieldsStage}
+
+	cursor, err := m.posts.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := []types.NicePost{}
+	err = cursor.All(ctx, &posts)
+	return posts, err
+}
+
+// postCounters are kept by votes and comments, never by edits.
+var postCounters = []string{"votes", "ups", "downs", "controversy", "hot", "rising", "comments"}
+
+func (m mongoPosts) Update(ctx context.Context, id primitive.ObjectID, post types.Post) error {
+	return update(ctx, m.posts, id, post, postCounters...)
+}
+
+func (m mongoPosts) ByLink(ctx context.Context, board primitive.ObjectID, link string) (types.Post, error) {
+	filter := bson.M{"board": board, "bodyType": types.Link, "bodyContent": link, "deletedAt": nil}
+	return findOne[types.Post](ctx, m.posts, filter)
+}
+
+func (m mongoPosts) Lookup(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]types.Post, error) {
+	found, err := findAll[types.Post](ctx, m.posts, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[primitive.ObjectID]types.Post{}
+	for _, post := range found {
+		result[post.ID] = post
+	}
+	return result, nil
+}
+
+func (m mongoPosts) Text(ctx context.Context, q search.Query, p Page) ([]Scored[types.Post], error) {
+	return textSearch[types.Post](ctx, m.posts, q, queryFilter(q, "title", "bodyContent"), p)
+}
+
+func (m mongoPosts) SoftDelete(ctx context.Context, board, id, by primitive.ObjectID) error {
+	return m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+		result, err := m.posts.UpdateOne(ctx, bson.M{"_id": id, "board": board, "deletedAt": nil}, deletion(by, nil))
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrNotFound
+		}
+
+		_, err = m.comments.UpdateMany(ctx, bson.M{"post": id, "deletedAt": nil}, deletion(by, &id))
+		return err
+	})
+}
+
+func (m mongoPosts) Restore(ctx context.Context, board, id primitive.ObjectID, since time.Time) error {
+	return m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+		return restore(ctx, m.posts, bson.M{"_id": id, "board": board}, id, since, m.comments)
+	})
+}
+
+func (m mongoPosts) Trash(ctx context.Context, board primitive.ObjectID, since time.Time, limit int64) ([]types.Post, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "deletedAt", Value: -1}}).SetLimit(limit)
+	return findAll[types.Post](ctx, m.posts, restorable(bson.M{"board": board}, since), opts)
+}
+
+func (m mongoPosts) Purge(ctx context.Context, before time.Time) (int, error) {
+	ids, err := distinctIds(ctx, m.posts, bson.M{"deletedAt": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		err := m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+			_, err := m.cascadePosts(ctx, bson.M{"_id": id})
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// BackfillRanks counts the comments and computes the ranks of the posts
+// stored before they existed.
+func BackfillRanks(ctx context.Context, db *mongo.Database) error {
+	posts := db.Collection("posts")
+	missing := bson.M{"$or": bson.A{
+		bson.M{"hot": bson.M{"$exists": false}},
+		bson.M{"rising": bson.M{"$exists": false}},
+		bson.M{"comments": bson.M{"$exists": false}},
+	}}
+
+	stale, err := posts.CountDocuments(ctx, missing)
+	if err != nil || stale == 0 {
+		return err
+	}
+
+	counts := mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$post"},
+			{Key: "comments", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$merge", Value: bson.D{
+			{Key: "into", Value: "posts"},
+			{Key: "on", Value: "_id"},
+			{Key: "whenMatched", Value: "merge"},
+			{Key: "whenNotMatched", Value: "discard"},
+		}}},
+	}
+	cursor, err := db.Collection("comments").Aggregate(ctx, counts)
+	if err != nil {
+		return err
+	}
+	cursor.Close(ctx)
+
+	uncounted := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "comments", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$comments", 0}}}},
+	}}}
+	_, err = posts.UpdateMany(ctx, missing, mongo.Pipeline{uncounted, rankStage()})
+	return err
+}
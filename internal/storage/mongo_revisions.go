@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRevisions struct{ *mongoDB }
+
+// revisionFilter matches a missing comment for the revisions of the post.
+func revisionFilter(post primitive.ObjectID, comment *primitive.ObjectID) bson.M {
+	return bson.M{"post": post, "comment": comment}
+}
+
+func (m mongoRevisions) Create(ctx context.Context, revision types.Revision) error {
+	_, err := insert(ctx, m.revisions, revision)
+	return err
+}
+
+func (m mongoRevisions) List(ctx context.Context, post primitive.ObjectID, comment *primitive.ObjectID, p Page) ([]types.Revision, error) {
+	p.Sort = ""
+	return findAll[types.Revision](ctx, m.revisions, pageFilter(p, revisionFilter(post, comment)), pageOptions(p))
+}
+
+func (m mongoRevisions) First(ctx context.Context, post primitive.ObjectID, comment *primitive.ObjectID) (types.Revision, error) {
+	var revision types.Revision
+	opts := options.FindOne().SetSort(bson.D{{Key: "_id", Value: 1}})
+	err := m.revisions.FindOne(ctx, revisionFilter(post, comment), opts).Decode(&revision)
+	return revision, notFound(err)
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/types"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRoles struct{ *mongoDB }
+
+func (m mongoRoles) Has(ctx context.Context, user primitive.ObjectID, role types.RoleName) (bool, error) {
+	err := m.roles.FindOne(ctx, bson.M{
+		"user": user,
+		"role": role,
+	}).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (m mongoRoles) Of(ctx context.Context, user primitive.ObjectID) ([]types.Role, error) {
+	return findAll[types.Role](ctx, m.roles, bson.M{"user": user})
+}
+
+func (m mongoRoles) audit(ctx context.Context, user primitive.ObjectID, role types.RoleName, action string, by primitive.ObjectID) {
+	_, err := m.roleAudit.InsertOne(ctx, types.RoleAudit{
+		User:   user,
+		Role:   role,
+		Action: action,
+		By:     by,
+		At:     time.Now(),
+	})
+	if err != nil {
+		log.Error("failed writing role audit", "user", user, "role", role, "error", err)
+	}
+}
+
+func (m mongoRoles) Grant(ctx context.Context, user primitive.ObjectID, role types.RoleName, by primitive.ObjectID) error {
+	if !role.Valid() {
+		return types.ErrUnknownRole
+	}
+
+	filter := bson.M{"user": user, "role": role}
+	update := bson.M{"$setOnInsert": types.Role{
+		User:      user,
+		Role:      role,
+		GrantedBy: by,
+		GrantedAt: time.Now(),
+	}}
+
+	result, err := m.roles.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+
+	if result.UpsertedCount == 1 {
+		m.audit(ctx, user, role, "grant", by)
+	}
+	return nil
+}
+
+func (m mongoRoles) Revoke(ctx context.Context, user primitive.ObjectID, role types.RoleName, by primitive.ObjectID) error {
+	if !role.Valid() {
+		return types.ErrUnknownRole
+	}
+
+	result, err := m.roles.DeleteMany(ctx, bson.M{"user": user, "role": role})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	m.audit(ctx, user, role, "revoke", by)
+	return nil
+}
+
+func (m mongoRoles) Audit(ctx context.Context) ([]types.RoleAudit, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: -1}})
+	return findAll[types.RoleAudit](ctx, m.roleAudit, bson.M{}, opts)
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoSessions struct{ *mongoDB }
+
+func (m mongoSessions) Create(ctx context.Context, session types.Session) error {
+	_, err := m.sessions.InsertOne(ctx, session)
+	return err
+}
+
+func (m mongoSessions) Get(ctx context.Context, id primitive.ObjectID) (types.Session, error) {
+	return findOne[types.Session](ctx, m.sessions, bson.M{"_id": id})
+}
+
+func (m mongoSessions) Rotate(ctx context.Context, hash, next string, expires time.Time) (types.Session, error) {
+	filter := bson.M{
+		"refreshHash":    hash,
+		"refreshExpires": bson.M{"$gt": time.Now()},
+	}
+	update := bson.M{"$set": bson.M{
+		"refreshHash":    next,
+		"refreshExpires": expires,
+	}}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var session types.Session
+	err := m.sessions.FindOneAndUpdate(ctx, filter, update, opts).Decode(&session)
+	return session, notFound(err)
+}
+
+func (m mongoSessions) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := m.sessions.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
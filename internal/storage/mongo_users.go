@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/search"
+	"redoot/internal/types"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoUsers struct{ *mongoDB }
+
+func (m mongoUsers) Create(ctx context.Context, user types.User) (primitive.ObjectID, error) {
+	created(&user.Timestamps)
+	return insert(ctx, m.users, user)
+}
+
+func (m mongoUsers) Get(ctx context.Context, id primitive.ObjectID) (types.User, error) {
+	return findOne[types.User](ctx, m.users, bson.M{"_id": id})
+}
+
+func (m mongoUsers) ByName(ctx context.Context, name string) (types.User, error) {
+	return findOne[types.User](ctx, m.users, bson.M{"name": name})
+}
+
+func (m mongoUsers) List(ctx context.Context, p Page) ([]types.User, error) {
+	return findAll[types.User](ctx, m.users, pageFilter(p, bson.M{}), pageOptions(p))
+}
+
+func (m mongoUsers) Lookup(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]types.User, error) {
+	found, err := findAll[types.User](ctx, m.users, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[primitive.ObjectID]types.User{}
+	for _, user := range found {
+		result[user.ID] = user
+	}
+	return result, nil
+}
+
+func (m mongoUsers) Update(ctx context.Context, id primitive.ObjectID, user types.User) error {
+	return update(ctx, m.users, id, user)
+}
+
+func (m mongoUsers) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+		result, err := m.users.DeleteOne(ctx, bson.M{"_id": id})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount != 1 {
+			return ErrNotFound
+		}
+		return m.orphan(ctx, id)
+	})
+}
+
+// orphan detaches everything referencing the user: posts and comments lose
+// their author, owned boards go to their first remaining moderator and
+// sessions, roles, subscriptions and notifications are dropped.
+func (m mongoUsers) orphan(ctx context.Context, id primitive.ObjectID) error {
+	unset := bson.M{"$set": bson.M{"author": primitive.NilObjectID}}
+	if _, err := m.posts.UpdateMany(ctx, bson.M{"author": id}, unset); err != nil {
+		return err
+	}
+	if _, err := m.comments.UpdateMany(ctx, bson.M{"author": id}, unset); err != nil {
+		return err
+	}
+
+	_, err := m.boards.UpdateMany(ctx, bson.M{"moderators": id}, bson.M{"$pull": bson.M{"moderators": id}})
+	if err != nil {
+		return err
+	}
+
+	owned, err := findAll[types.Board](ctx, m.boards, bson.M{"owner": id})
+	if err != nil {
+		return err
+	}
+
+	for _, board := range owned {
+		owner := primitive.NilObjectID
+		mods := slices.DeleteFunc(board.Moderators, func(m primitive.ObjectID) bool { return m == id })
+		if len(mods) > 0 {
+			owner = mods[0]
+		}
+		_, err := m.boards.UpdateByID(ctx, board.ID, bson.M{"$set": bson.M{"owner": owner}})
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := m.sessions.DeleteMany(ctx, bson.M{"user": id}); err != nil {
+		return err
+	}
+	if _, err := m.roles.DeleteMany(ctx, bson.M{"user": id}); err != nil {
+		return err
+	}
+
+	if _, err := m.subscriptions.DeleteMany(ctx, bson.M{"user": id}); err != nil {
+		return err
+	}
+	if _, err := m.notifications.DeleteMany(ctx, bson.M{"user": id}); err != nil {
+		return err
+	}
+	_, err = m.notifications.UpdateMany(ctx, bson.M{"actor": id}, bson.M{"$set": bson.M{"actor": primitive.NilObjectID}})
+	return err
+}
+
+func (m mongoUsers) Search(ctx context.Context, field, value string) ([]types.User, error) {
+	return regexSearch[types.User](ctx, m.users, field, value, false)
+}
+
+func (m mongoUsers) Text(ctx context.Context, q search.Query, p Page) ([]Scored[types.User], error) {
+	return textSearch[types.User](ctx, m.users, q, queryFilter(q, "name", "bio"), p)
+}
+
+func (m mongoUsers) Popular(ctx context.Context, limit int64) ([]types.PopularUser, error) {
+	lookupPostsStage := bson.D{
+		{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "posts"},
+			{Key: "localField", Value: "_id"},
+			{Key: "foreignField", Value: "author"},
+			{Key: "as", Value: "posts"},
+		}},
+	}
+
+	lookupCommentsStage := bson.D{
+		{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "comments"},
+			{Key: "localField", Value: "_id"},
+			{Key: "foreignField", Value: "author"},
+			{Key: "as", Value: "comments"},
+		}},
+	}
+
+	projectStage := bson.D{
+		{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "name", Value: 1},
+			{Key: "votes", Value: bson.D{
+				{Key: "$add", Value: bson.A{
+					bson.D{{Key: "$sum", Value: "$posts.votes"}},
+					bson.D{{Key: "$sum", Value: "$comments.votes"}},
+				}},
+			}},
+		}},
+	}
+
+	sortStage := bson.D{
+		{Key: "$sort", Value: bson.D{
+			{Key: "votes", Value: -1},
+		}},
+	}
+
+	limitStage := bson.D{
+		{Key: "$limit", Value: limit},
+	}
+
+	pipeline := mongo.Pipeline{lookupPostsStage, lookupCommentsStage, projectStage, sortStage, limitStage}
+
+	cursor, err := m.users.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	answers := []types.PopularUser{}
+	err = cursor.All(ctx, &answers)
+	return answers, err
+}
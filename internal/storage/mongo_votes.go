@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"redoot/internal/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoVotes struct{ *mongoDB }
+
+// Cast keeps the vote and moves the score of the target in one transaction,
+// the score never counts a vote the votes collection doesn't have. The
+// unique index on user and target turns concurrent first votes into a
+// duplicate key error for all but one, those are cast again over the vote
+// that won.
+func (m mongoVotes) Cast(ctx context.Context, user, target primitive.ObjectID, kind types.VoteTarget, direction int) (int, error) {
+	for {
+		votes, err := m.cast(ctx, user, target, kind, direction)
+		if !mongo.IsDuplicateKeyError(err) || ctx.Err() != nil {
+			return votes, err
+		}
+	}
+}
+
+func (m mongoVotes) cast(ctx context.Context, user, target primitive.ObjectID, kind types.VoteTarget, direction int) (int, error) {
+	filter := bson.M{"user": user, "target": target}
+
+	targets := m.posts
+	if kind == types.VoteComment {
+		targets = m.comments
+	}
+
+	var result struct {
+		Votes int `bson:"votes"`
+	}
+	err := m.withTransaction(ctx, func(ctx mongo.SessionContext) error {
+		var previous types.Vote
+		var err error
+		if direction == 0 {
+			err = m.votes.FindOneAndDelete(ctx, filter).Decode(&previous)
+		} else {
+			update := bson.M{
+				"$set": bson.M{"direction": direction},
+				"$setOnInsert": bson.M{
+					"user":       user,
+					"target":     target,
+					"targetType": kind,
+				},
+			}
+			opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+			err = m.votes.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+		}
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		pipeline := scoreUpdate(previous.Direction, direction)
+		if kind == types.VotePost {
+			pipeline = append(pipeline, rankStage())
+		}
+		err = targets.FindOneAndUpdate(ctx, bson.M{"_id": target}, pipeline, opts).Decode(&result)
+		return notFound(err)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Votes, nil
+}
+
+// scoreUpdate moves votes, ups and downs from the previous vote to the new
+// one and recomputes the controversy in the same update so the stored fields
+// never disagree.
+func scoreUpdate(previous, direction int) mongo.Pipeline {
+	oldUps, oldDowns := counts(previous)
+	newUps, newDowns := counts(direction)
+
+	inc := func(field string, delta int) bson.D {
+		return bson.D{{Key: "$add", Value: bson.A{
+			bson.D{{Key: "$ifNull", Value: bson.A{"$" + field, 0}}},
+			delta,
+		}}}
+	}
+
+	return mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{
+			{Key: "votes", Value: inc("votes", direction-previous)},
+			{Key: "ups", Value: inc("ups", newUps-oldUps)},
+			{Key: "downs", Value: inc("downs", newDowns-oldDowns)},
+		}}},
+		{{Key: "$set", Value: bson.D{
+			{Key: "controversy", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$and", Value: bson.A{
+					bson.D{{Key: "$gt", Value: bson.A{"$ups", 0}}},
+					bson.D{{Key: "$gt", Value: bson.A{"$downs", 0}}},
+				}}},
+				bson.D{{Key: "$pow", Value: bson.A{
+					bson.D{{Key: "$add", Value: bson.A{"$ups", "$downs"}}},
+					bson.D{{Key: "$divide", Value: bson.A{
+						bson.D{{Key: "$min", Value: bson.A{"$ups", "$downs"}}},
+						bson.D{{Key: "$max", Value: bson.A{"$ups", "$downs"}}},
+					}}},
+				}}},
+				0,
+			}}}},
+		}}},
+	}
+}
+
+// rankStage recomputes the stored ranks of a post after its votes or
+// comments changed.
+func rankStage() bson.D {
+	return bson.D{{Key: "$set", Value: bson.D{
+		{Key: "hot", Value: hotExpr()},
+		{Key: "rising", Value: risingExpr()},
+	}}}
+}
+
+// hotExpr computes hot from the stored votes and the time in the _id the
+// same way hot does.
+func hotExpr() bson.D {
+	seconds := bson.D{{Key: "$divide", Value: bson.A{
+		bson.D{{Key: "$toLong", Value: bson.D{{Key: "$toDate", Value: "$_id"}}}},
+		1000,
+	}}}
+	order := bson.D{{Key: "$log10", Value: bson.D{{Key: "$max", Value: bson.A{
+		bson.D{{Key: "$abs", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$votes", 0}}}}},
+		1,
+	}}}}}
+
+	return bson.D{{Key: "$add", Value: bson.A{
+		bson.D{{Key: "$multiply", Value: bson.A{
+			bson.D{{Key: "$cmp", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$votes", 0}}}, 0}}},
+			order,
+		}}},
+		bson.D{{Key: "$divide", Value: bson.A{
+			bson.D{{Key: "$subtract", Value: bson.A{seconds, hotEpoch}}},
+			hotDecay,
+		}}},
+	}}}
+}
+
+// risingExpr computes rising from the stored votes and comments the same way
+// rising does.
+func risingExpr() bson.D {
+	hours := bson.D{{Key: "$divide", Value: bson.A{
+		bson.D{{Key: "$subtract", Value: bson.A{"$$NOW", bson.D{{Key: "$toDate", Value: "$_id"}}}}},
+		time.Hour.Milliseconds(),
+	}}}
+
+	return bson.D{{Key: "$divide", Value: bson.A{
+		bson.D{{Key: "$add", Value: bson.A{
+			bson.D{{Key: "$ifNull", Value: bson.A{"$votes", 0}}},
+			bson.D{{Key: "$ifNull", Value: bson.A{"$comments", 0}}},
+		}}},
+		bson.D{{Key: "$max", Value: bson.A{hours, 1}}},
+	}}}
+}
+
+func (m mongoVotes) Of(ctx context.Context, user primitive.ObjectID, targets []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	result := map[primitive.ObjectID]int{}
+	if len(targets) == 0 {
+		return result, nil
+	}
+
+	found, err := findAll[types.Vote](ctx, m.votes, bson.M{
+		"user":   user,
+		"target": bson.M{"$in": targets},
+	})
+	if err != nil {
+		return result, err
+	}
+
+	for _, v := range found {
+		result[v.Target] = v.Direction
+	}
+	return result, nil
+}
@@ -0,0 +1,322 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"math"
+	"redoot/internal/search"
+	"redoot/internal/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrNotModified = errors.New("nothing was modified")
+)
+
+// Sort fields understood by the stores, an empty sort lists newest first.
+const (
+	SortVotes       = "votes"
+	SortControversy = "controversy"
+	// SortHot orders posts by votes decayed by age.
+	SortHot = "hot"
+	// SortRising orders posts by votes and comments per hour of age.
+	SortRising = "rising"
+	// SortScore orders search results by relevance.
+	SortScore = "score"
+)
+
+// Page selects a slice of a listing sorted descending by Sort. Listings
+// return up to Limit+1 items, the extra one only tells there is a next page.
+type Page struct {
+	Limit int64
+	Sort  string
+	After *Cursor
+	// Since leaves out documents created before it.
+	Since *time.Time
+}
+
+// Cursor is the position of the last item of the previous page.
+type Cursor struct {
+	Value float64            `json:"v,omitempty"`
+	ID    primitive.ObjectID `json:"id"`
+}
+
+// Scored is a search result together with its relevance, higher is better.
+type Scored[T any] struct {
+	Doc   T
+	Score float64
+}
+
+// Dump is everything export and import move around.
+type Dump struct {
+	Users    []types.User    `json:"users"`
+	Boards   []types.Board   `json:"boards"`
+	Posts    []types.Post    `json:"posts"`
+	Comments []types.Comment `json:"comments"`
+}
+
+type UserStore interface {
+	Create(ctx context.Context, user types.User) (primitive.ObjectID, error)
+	Get(ctx context.Context, id primitive.ObjectID) (types.User, error)
+	ByName(ctx context.Context, name string) (types.User, error)
+	List(ctx context.Context, p Page) ([]types.User, error)
+	// Lookup returns the users with the ids keyed by id.
+	Lookup(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]types.User, error)
+	Update(ctx context.Context, id primitive.ObjectID, user types.User) error
+	// Delete removes the user and detaches everything referencing them, owned
+	// boards go to their first remaining moderator.
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	Search(ctx context.Context, field, value string) ([]types.User, error)
+	// Text runs a search over names and bios, most relevant first, filters
+	// only posts and comments have match no user.
+	Text(ctx context.Context, q search.Query, p Page) ([]Scored[types.User], error)
+	Popular(ctx context.Context, limit int64) ([]types.PopularUser, error)
+}
+
+type BoardStore interface {
+	Create(ctx context.Context, board types.Board) (primitive.ObjectID, error)
+	Get(ctx context.Context, id primitive.ObjectID) (types.Board, error)
+	// GetAny also returns deleted boards.
+	GetAny(ctx context.Context, id primitive.ObjectID) (types.Board, error)
+	List(ctx context.Context, p Page) ([]types.Board, error)
+	Update(ctx context.Context, id primitive.ObjectID, board types.Board) error
+	Search(ctx context.Context, field, value string) ([]types.Board, error)
+	// Text runs a search over live boards, most relevant first, filters only
+	// posts and comments have match no board.
+	Text(ctx context.Context, q search.Query, p Page) ([]Scored[types.Board], error)
+	// SoftDelete marks the board and everything alive in it as deleted.
+	SoftDelete(ctx context.Context, id, by primitive.ObjectID) error
+	// Restore brings back a board deleted after since together with what
+	// was deleted with it.
+	Restore(ctx context.Context, id primitive.ObjectID, since time.Time) error
+	// Purge removes boards deleted before the time with all their content.
+	Purge(ctx context.Context, before time.Time) (int, error)
+}
+
+type PostStore interface {
+	Create(ctx context.Context, post types.Post) (primitive.ObjectID, error)
+	Get(ctx context.Context, board, id primitive.ObjectID) (types.Post, error)
+	// GetAny also returns deleted posts.
+	GetAny(ctx context.Context, board, id primitive.ObjectID) (types.Post, error)
+	List(ctx context.Context, board primitive.ObjectID, p Page) ([]types.Post, error)
+	// Popular lists the posts of every board with author and board names.
+	Popular(ctx context.Context, p Page) ([]types.NicePost, error)
+	// Feed is Popular narrowed down to the boards.
+	Feed(ctx context.Context, boards []primitive.ObjectID, p Page) ([]types.NicePost, error)
+	// ByLink returns the live link post of the board pointing at the link.
+	ByLink(ctx context.Context, board primitive.ObjectID, link string) (types.Post, error)
+	// Lookup returns the posts with the ids keyed by id, deleted ones
+	// included.
+	Lookup(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]types.Post, error)
+	Update(ctx context.Context, id primitive.ObjectID, post types.Post) error
+	// Text runs a search over live posts, most relevant first.
+	Text(ctx context.Context, q search.Query, p Page) ([]Scored[types.Post], error)
+	// SoftDelete marks the post and its comments as deleted.
+	SoftDelete(ctx context.Context, board, id, by primitive.ObjectID) error
+	Restore(ctx context.Context, board, id primitive.ObjectID, since time.Time) error
+	// Trash lists the posts of the board deleted on their own after since.
+	Trash(ctx context.Context, board primitive.ObjectID, since time.Time, limit int64) ([]types.Post, error)
+	// Purge removes posts deleted before the time with their comments and votes.
+	Purge(ctx context.Context, before time.Time) (int, error)
+}
+
+type CommentStore interface {
+	// Create also counts the comment on its post and rescores how fast the
+	// post is rising.
+	Create(ctx context.Context, comment types.Comment) (primitive.ObjectID, error)
+	Get(ctx context.Context, post, id primitive.ObjectID) (types.Comment, error)
+	// GetAny also returns deleted comments.
+	GetAny(ctx context.Context, post, id primitive.ObjectID) (types.Comment, error)
+	List(ctx context.Context, post primitive.ObjectID, p Page) ([]types.Comment, error)
+	// Thread lists the replies to parent, or the top level comments when it's
+	// nil, deleted ones included so the tree can keep their replies.
+	Thread(ctx context.Context, post primitive.ObjectID, parent *primitive.ObjectID, p Page) ([]types.Comment, error)
+	// Replies lists up to one more than the limit of replies to each of the
+	// parents sorted by p.Sort, deleted ones included, the cursor is ignored.
+	Replies(ctx context.Context, parents []primitive.ObjectID, p Page) ([]types.Comment, error)
+	// Answered tells which of the comments have replies.
+	Answered(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]bool, error)
+	Update(ctx context.Context, id primitive.ObjectID, comment types.Comment) error
+	// Text runs a search over live comments, most relevant first, the type
+	// filter matches no comment.
+	Text(ctx context.Context, q search.Query, p Page) ([]Scored[types.Comment], error)
+	// SoftDelete only marks the comment itself, replies stay.
+	SoftDelete(ctx context.Context, post, id, by primitive.ObjectID) error
+	Restore(ctx context.Context, post, id primitive.ObjectID, since time.Time) error
+	// Trash lists the comments on live posts of the board deleted on their
+	// own after since.
+	Trash(ctx context.Context, board primitive.ObjectID, since time.Time, limit int64) ([]types.Comment, error)
+	// Purge removes comments deleted before the time, ones with replies are
+	// kept as a placeholder.
+	Purge(ctx context.Context, before time.Time) (int, error)
+}
+
+type VoteStore interface {
+	// Cast records the vote of the user and returns the new score of the
+	// target, a direction of 0 removes the vote.
+	Cast(ctx context.Context, user, target primitive.ObjectID, kind types.VoteTarget, direction int) (int, error)
+	// Of returns the votes of the user on the targets.
+	Of(ctx context.Context, user primitive.ObjectID, targets []primitive.ObjectID) (map[primitive.ObjectID]int, error)
+}
+
+type SessionStore interface {
+	Create(ctx context.Context, session types.Session) error
+	Get(ctx context.Context, id primitive.ObjectID) (types.Session, error)
+	// Rotate swaps the refresh hash of the unexpired session holding hash.
+	Rotate(ctx context.Context, hash, next string, expires time.Time) (types.Session, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+type RoleStore interface {
+	Has(ctx context.Context, user primitive.ObjectID, role types.RoleName) (bool, error)
+	Of(ctx context.Context, user primitive.ObjectID) ([]types.Role, error)
+	// Grant is idempotent, granting a role the user already holds keeps the
+	// original grant.
+	Grant(ctx context.Context, user primitive.ObjectID, role types.RoleName, by primitive.ObjectID) error
+	// Revoke returns ErrNotFound when the user didn't hold the role.
+	Revoke(ctx context.Context, user primitive.ObjectID, role types.RoleName, by primitive.ObjectID) error
+	Audit(ctx context.Context) ([]types.RoleAudit, error)
+}
+
+type NotificationStore interface {
+	Create(ctx context.Context, notifications []types.Notification) error
+	// List pages through the notifications of the user newest first, unread
+	// leaves out read ones and an empty kind matches every type.
+	List(ctx context.Context, user primitive.ObjectID, unread bool, kind types.NotificationType, p Page) ([]types.Notification, error)
+	// Unread counts the unread notifications of the user by type.
+	Unread(ctx context.Context, user primitive.ObjectID) (map[types.NotificationType]int, error)
+	// MarkRead returns ErrNotFound when the user has no such notification.
+	MarkRead(ctx context.Context, user, id primitive.ObjectID) error
+	// MarkAllRead returns how many notifications were unread.
+	MarkAllRead(ctx context.Context, user primitive.ObjectID) (int, error)
+}
+
+type SubscriptionStore interface {
+	// Subscribe is idempotent, subscribing again keeps the first subscription.
+	Subscribe(ctx context.Context, user, board primitive.ObjectID) error
+	// Unsubscribe returns ErrNotFound when the user wasn't subscribed.
+	Unsubscribe(ctx context.Context, user, board primitive.ObjectID) error
+	Subscribers(ctx context.Context, board primitive.ObjectID) ([]primitive.ObjectID, error)
+	// Of lists the subscriptions of the user newest first.
+	Of(ctx context.Context, user primitive.ObjectID) ([]types.Subscription, error)
+}
+
+type RevisionStore interface {
+	Create(ctx context.Context, revision types.Revision) error
+	// List pages through the revisions of the post, or of the comment when
+	// it isn't nil, newest first.
+	List(ctx context.Context, post primitive.ObjectID, comment *primitive.ObjectID, p Page) ([]types.Revision, error)
+	// First returns the oldest revision, holding the content as it was
+	// posted, and ErrNotFound when it was never edited.
+	First(ctx context.Context, post primitive.ObjectID, comment *primitive.ObjectID) (types.Revision, error)
+}
+
+type Backup interface {
+	Export(ctx context.Context) (Dump, error)
+	Import(ctx context.Context, dump Dump) error
+}
+
+// BlobStore keeps the bytes of uploaded media. Blobs never change, putting
+// an id that is already stored keeps the first one.
+type BlobStore interface {
+	Put(ctx context.Context, media types.Media, data []byte) error
+	Get(ctx context.Context, id string) (types.Media, []byte, error)
+	// Stat returns the description without reading the bytes.
+	Stat(ctx context.Context, id string) (types.Media, error)
+}
+
+type Stores struct {
+	Users    UserStore
+	Boards   BoardStore
+	Posts    PostStore
+	Comments CommentStore
+	Votes    VoteStore
+	Sessions SessionStore
+	Roles    RoleStore
+	Backup   Backup
+
+	Notifications NotificationStore
+	Subscriptions SubscriptionStore
+	Revisions     RevisionStore
+	// Blobs is chosen by the config and set by the caller, see NewFiles and
+	// NewGridFS.
+	Blobs BlobStore
+}
+
+// DeletedPlaceholder replaces the body of purged comments that still have
+// replies and the name of authors that deleted their account.
+const DeletedPlaceholder = "[deleted]"
+
+// DeletedPlaceholderHTML is the placeholder rendered.
+const DeletedPlaceholderHTML = "<p>" + DeletedPlaceholder + "</p>\n"
+
+// stamp is the current time as precise as mongo stores it, so both stores
+// hand out the same timestamps.
+func stamp() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// created stamps a new document, whatever timestamps it came with.
+func created(t *types.Timestamps) {
+	now := stamp()
+	t.CreatedAt, t.UpdatedAt = now, now
+}
+
+// backdate fills in the timestamps a document was imported without from the
+// creation time in its id.
+func backdate(t *types.Timestamps, id primitive.ObjectID) {
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = id.Timestamp().UTC()
+	}
+	if t.UpdatedAt.IsZero() {
+		t.UpdatedAt = t.CreatedAt
+	}
+}
+
+func counts(direction int) (ups int, downs int) {
+	switch direction {
+	case 1:
+		return 1, 0
+	case -1:
+		return 0, 1
+	}
+	return 0, 0
+}
+
+// controversy is (ups + downs) ^ (min / max), zero unless the votes are split.
+func controversy(ups, downs int) float64 {
+	if ups == 0 || downs == 0 {
+		return 0
+	}
+	low, high := float64(min(ups, downs)), float64(max(ups, downs))
+	return math.Pow(float64(ups+downs), low/high)
+}
+
+// hotEpoch is where hot scores start, it only keeps them small.
+const hotEpoch = 1704067200
+
+// hotDecay is how many seconds of age weigh as much as a tenfold score.
+const hotDecay = 45000
+
+// hot is log10 of the score signed plus the age of the post, a post needs ten
+// times the votes to keep up with one posted 12.5 hours later.
+func hot(votes int, id primitive.ObjectID) float64 {
+	sign := 0.0
+	if votes > 0 {
+		sign = 1
+	} else if votes < 0 {
+		sign = -1
+	}
+	order := math.Log10(math.Max(math.Abs(float64(votes)), 1))
+	return sign*order + float64(id.Timestamp().Unix()-hotEpoch)/hotDecay
+}
+
+// rising is the votes and comments the post gathered per hour of its age at
+// now, posts younger than an hour count as an hour old.
+func rising(votes, comments int, id primitive.ObjectID, now time.Time) float64 {
+	hours := math.Max(now.Sub(id.Timestamp()).Hours(), 1)
+	return float64(votes+comments) / hours
+}
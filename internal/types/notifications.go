@@ -0,0 +1,109 @@
+package types
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type NotificationType string
+
+const (
+	// CommentReply is a reply to a comment of the user.
+	CommentReply NotificationType = "comment_reply"
+	// PostReply is a top level comment on a post of the user.
+	PostReply NotificationType = "post_reply"
+	Mention   NotificationType = "mention"
+	// Moderation is a moderator deleting, restoring or editing something the
+	// user wrote.
+	Moderation NotificationType = "moderation"
+	// BoardPost is a new post in a board the user subscribed to.
+	BoardPost NotificationType = "board_post"
+)
+
+// Actions of moderation notifications.
+const (
+	ActionDeleted  = "deleted"
+	ActionRestored = "restored"
+	ActionEdited   = "edited"
+)
+
+var NotificationTypes = []NotificationType{CommentReply, PostReply, Mention, Moderation, BoardPost}
+
+func (t NotificationType) Valid() bool {
+	switch t {
+	case CommentReply, PostReply, Mention, Moderation, BoardPost:
+		return true
+	}
+	return false
+}
+
+type Notification struct {
+	ID   primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	User primitive.ObjectID `json:"user" bson:"user"`
+	Type NotificationType   `json:"type" bson:"type"`
+	// Actor is who caused the notification.
+	Actor   primitive.ObjectID  `json:"actor" bson:"actor"`
+	Board   primitive.ObjectID  `json:"board" bson:"board"`
+	Post    *primitive.ObjectID `json:"post,omitempty" bson:"post,omitempty"`
+	Comment *primitive.ObjectID `json:"comment,omitempty" bson:"comment,omitempty"`
+	// Action is what a moderator did: deleted, restored or edited.
+	Action    string    `json:"action,omitempty" bson:"action,omitempty"`
+	Read      bool      `json:"read" bson:"read"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+type Subscription struct {
+	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	User      primitive.ObjectID `json:"user" bson:"user"`
+	Board     primitive.ObjectID `json:"board" bson:"board"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// Muted holds the notification types a user turned off, the zero value
+// gets every notification.
+type Muted struct {
+	CommentReply bool `bson:"commentReply,omitempty"`
+	PostReply    bool `bson:"postReply,omitempty"`
+	Mention      bool `bson:"mention,omitempty"`
+	Moderation   bool `bson:"moderation,omitempty"`
+	BoardPost    bool `bson:"boardPost,omitempty"`
+}
+
+func (m *Muted) flag(t NotificationType) *bool {
+	switch t {
+	case CommentReply:
+		return &m.CommentReply
+	case PostReply:
+		return &m.PostReply
+	case Mention:
+		return &m.Mention
+	case Moderation:
+		return &m.Moderation
+	case BoardPost:
+		return &m.BoardPost
+	}
+	return nil
+}
+
+// Wants reports whether the user gets notifications of the type.
+func (m Muted) Wants(t NotificationType) bool {
+	flag := m.flag(t)
+	return flag == nil || !*flag
+}
+
+// Set turns the notifications of a valid type on or off.
+func (m *Muted) Set(t NotificationType, enabled bool) {
+	if flag := m.flag(t); flag != nil {
+		*flag = !enabled
+	}
+}
+
+// Preferences lists every type with whether the user gets it.
+func (m Muted) Preferences() map[NotificationType]bool {
+	prefs := map[NotificationType]bool{}
+	for _, t := range NotificationTypes {
+		prefs[t] = m.Wants(t)
+	}
+	return prefs
+}
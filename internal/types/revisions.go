@@ -0,0 +1,21 @@
+package types
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Revision is what a post or comment was before an edit, Comment is nil for
+// the revisions of the post itself and Title is only kept for posts. Diff
+// turns Body into the body after the edit, see diff.Lines.
+type Revision struct {
+	ID        primitive.ObjectID  `json:"id,omitempty" bson:"_id,omitempty"`
+	Post      primitive.ObjectID  `json:"post" bson:"post"`
+	Comment   *primitive.ObjectID `json:"comment,omitempty" bson:"comment,omitempty"`
+	Editor    primitive.ObjectID  `json:"editor" bson:"editor"`
+	Title     string              `json:"title,omitempty" bson:"title,omitempty"`
+	Body      string              `json:"body" bson:"body"`
+	Diff      string              `json:"diff" bson:"diff"`
+	CreatedAt time.Time           `json:"createdAt" bson:"createdAt"`
+}
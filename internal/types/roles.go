@@ -0,0 +1,80 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RoleName string
+
+const (
+	RoleAdmin     RoleName = "admin"
+	RoleModerator RoleName = "moderator"
+	RoleUser      RoleName = "user"
+)
+
+var ErrUnknownRole = errors.New("unknown role")
+
+type Role struct {
+	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	User      primitive.ObjectID `json:"user" bson:"user"`
+	Role      RoleName           `json:"role" bson:"role"`
+	GrantedBy primitive.ObjectID `json:"grantedBy" bson:"grantedBy"`
+	GrantedAt time.Time          `json:"grantedAt" bson:"grantedAt"`
+}
+
+type RoleAudit struct {
+	ID     primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	User   primitive.ObjectID `json:"user" bson:"user"`
+	Role   RoleName           `json:"role" bson:"role"`
+	Action string             `json:"action" bson:"action"`
+	By     primitive.ObjectID `json:"by" bson:"by"`
+	At     time.Time          `json:"at" bson:"at"`
+}
+
+func (r RoleName) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleModerator:
+		return true
+	}
+	return false
+}
+
+// RoleLookup answers the role checks of HasRole, Roles is set on startup to
+// the role store of the configured storage.
+type RoleLookup interface {
+	Has(ctx context.Context, user primitive.ObjectID, role RoleName) (bool, error)
+}
+
+var Roles RoleLookup
+
+// QueryTimeout bounds the lookups of this package, it's set on startup to
+// the configured query timeout.
+var QueryTimeout = time.Millisecond * 200
+
+// HasRole reports whether the user holds the role, every user implicitly
+// holds RoleUser so it's never stored.
+func HasRole(u User, role RoleName) bool {
+	if role == RoleUser {
+		return true
+	}
+
+	if Roles == nil {
+		log.Error("missing role lookup; types package")
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), QueryTimeout)
+	defer cancel()
+
+	ok, err := Roles.Has(ctx, u.ID, role)
+	if err != nil {
+		log.Error("failed looking up role", "user", u.ID, "role", role, "error", err)
+	}
+
+	return ok
+}
@@ -7,22 +7,12 @@ import (
 	"slices"
 	"time"
 
-	"github.com/charmbracelet/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var (
-	Administrators []User
-	Collections    = struct {
-		Admins []User
-		Users  *mongo.Collection
-		Client *mongo.Client
-	}{}
-)
-
 var ErrPostEditPermissions = errors.New("User cannot edit the post!")
 
 type ContentType int
@@ -41,6 +31,35 @@ type User struct {
 	Pronouns string             `json:"pronouns" bson:"pronouns"`
 	Password string             `json:"password" bson:"password"`
 	Email    string             `json:"email" bson:"email"`
+	// Muted is changed through the notification preferences only.
+	Muted      Muted `json:"-" bson:"muted"`
+	Timestamps `bson:",inline"`
+}
+
+// Profile is what anyone can see of a user.
+type Profile struct {
+	ID        primitive.ObjectID `json:"id"`
+	Name      string             `json:"name"`
+	Bio       string             `json:"bio"`
+	Avatar    string             `json:"avatar"`
+	Pronouns  string             `json:"pronouns"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+// Account is what the user themself and admins see, the password hash is
+// never shown to anyone.
+type Account struct {
+	Profile
+	Email     string    `json:"email"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (u User) Profile() Profile {
+	return Profile{ID: u.ID, Name: u.Name, Bio: u.Bio, Avatar: u.Avatar, Pronouns: u.Pronouns, CreatedAt: u.CreatedAt}
+}
+
+func (u User) Account() Account {
+	return Account{Profile: u.Profile(), Email: u.Email, UpdatedAt: u.UpdatedAt}
 }
 
 func (u User) Equal(o User) bool {
@@ -62,17 +81,6 @@ func (u User) Equal(o User) bool {
 	return true
 }
 
-func (u User) IsTaken() bool {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
-
-	err := Collections.Users.FindOne(ctx, bson.M{
-		"name": u.Name,
-	}).Decode(nil)
-
-	return err == mongo.ErrNoDocuments
-}
-
 type Board struct {
 	ID         primitive.ObjectID   `json:"id,omitempty" bson:"_id,omitempty"`
 	Name       string               `json:"name" bson:"name"`
@@ -80,6 +88,8 @@ type Board struct {
 	Moderators []primitive.ObjectID `json:"moderators" bson:"moderators"`
 	Owner      primitive.ObjectID   `json:"owner" bson:"owner"`
 	Rules      string               `json:"rules" bson:"rules"`
+	Timestamps `bson:",inline"`
+	Deletion   `bson:",inline"`
 }
 
 type Post struct {
@@ -87,26 +97,130 @@ type Post struct {
 	Title       string             `json:"title" bson:"title"`
 	BodyType    ContentType        `json:"bodyType" bson:"bodyType"`
 	BodyContent string             `json:"bodyContent" bson:"bodyContent"`
+	// BodyHTML is the rendered Markdown of text posts.
+	BodyHTML    string             `json:"bodyHtml" bson:"bodyHtml"`
 	Votes       int                `json:"votes" bson:"votes"`
+	Ups         int                `json:"ups" bson:"ups"`
+	Downs       int                `json:"downs" bson:"downs"`
+	Controversy float64            `json:"controversy" bson:"controversy"`
+	Hot         float64            `json:"hot" bson:"hot"`
+	Rising      float64            `json:"rising" bson:"rising"`
+	Comments    int                `json:"comments" bson:"comments"`
 	Author      primitive.ObjectID `json:"author" bson:"author"`
 	Board       primitive.ObjectID `json:"board" bson:"board"`
+	Image       *ImageInfo         `json:"image,omitempty" bson:"image,omitempty"`
+	MyVote      int                `json:"myVote,omitempty" bson:"-"`
+	Timestamps  `bson:",inline"`
+	Edit        `bson:",inline"`
+	Deletion    `bson:",inline"`
+}
+
+// ImageInfo is what was read from the image of an Image post.
+type ImageInfo struct {
+	Format string `json:"format" bson:"format"`
+	Width  int    `json:"width" bson:"width"`
+	Height int    `json:"height" bson:"height"`
+	Size   int    `json:"size" bson:"size"`
+}
+
+// Media is an uploaded image, its ID is the hex sha256 of the bytes so the
+// same file uploaded twice is only stored once.
+type Media struct {
+	ID          string `json:"id" bson:"_id"`
+	ContentType string `json:"contentType" bson:"contentType"`
+	ImageInfo   `bson:",inline"`
+	Uploader    primitive.ObjectID `json:"uploader" bson:"uploader"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
 }
 
 type Comment struct {
-	ID     primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	Author primitive.ObjectID `json:"author" bson:"author"`
-	Post   primitive.ObjectID `json:"post" bson:"post"`
-	Body   string             `json:"body" bson:"body"`
-	Votes  int                `json:"votes" bson:"votes"`
+	ID          primitive.ObjectID  `json:"id,omitempty" bson:"_id,omitempty"`
+	Author      primitive.ObjectID  `json:"author" bson:"author"`
+	Post        primitive.ObjectID  `json:"post" bson:"post"`
+	Parent      *primitive.ObjectID `json:"parent,omitempty" bson:"parent,omitempty"`
+	Body        string              `json:"body" bson:"body"`
+	BodyHTML    string              `json:"bodyHtml" bson:"bodyHtml"`
+	Votes       int                 `json:"votes" bson:"votes"`
+	Ups         int                 `json:"ups" bson:"ups"`
+	Downs       int                 `json:"downs" bson:"downs"`
+	Controversy float64             `json:"controversy" bson:"controversy"`
+	MyVote      int                 `json:"myVote,omitempty" bson:"-"`
+	Purged      bool                `json:"-" bson:"purged,omitempty"`
+	Timestamps  `bson:",inline"`
+	Edit        `bson:",inline"`
+	Deletion    `bson:",inline"`
+}
+
+// Timestamps are stamped by the stores, what clients send is ignored.
+type Timestamps struct {
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Edit marks content changed after it was posted, what it was before is
+// kept as a Revision.
+type Edit struct {
+	Edited   bool       `json:"edited" bson:"edited,omitempty"`
+	EditedAt *time.Time `json:"editedAt,omitempty" bson:"editedAt,omitempty"`
+}
+
+// Deletion marks soft deleted documents, DeletedWith is set when the document
+// was deleted as part of its board or post and is restored together with it.
+type Deletion struct {
+	DeletedAt   *time.Time          `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	DeletedBy   *primitive.ObjectID `json:"deletedBy,omitempty" bson:"deletedBy,omitempty"`
+	DeletedWith *primitive.ObjectID `json:"-" bson:"deletedWith,omitempty"`
+}
+
+// CommentNode is a comment together with its replies, More links to the
+// replies that didn't fit into the response.
+type CommentNode struct {
+	Comment
+	Replies []CommentNode `json:"replies"`
+	More    string        `json:"more,omitempty"`
+}
+
+type VoteTarget string
+
+const (
+	VotePost    VoteTarget = "post"
+	VoteComment VoteTarget = "comment"
+)
+
+type Vote struct {
+	ID         primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	User       primitive.ObjectID `json:"user" bson:"user"`
+	Target     primitive.ObjectID `json:"target" bson:"target"`
+	TargetType VoteTarget         `json:"targetType" bson:"targetType"`
+	Direction  int                `json:"direction" bson:"direction"`
 }
 
 type NicePost struct {
-	Title       string      `json:"title" bson:"title"`
-	BodyType    ContentType `json:"bodyType" bson:"bodyType"`
-	BodyContent string      `json:"bodyContent" bson:"bodyContent"`
-	Author      string      `json:"author" bson:"author"`
-	Votes       int         `json:"votes" bson:"votes"`
-	Board       string      `json:"board" bson:"board"`
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	Title       string             `json:"title" bson:"title"`
+	BodyType    ContentType        `json:"bodyType" bson:"bodyType"`
+	BodyContent string             `json:"bodyContent" bson:"bodyContent"`
+	BodyHTML    string             `json:"bodyHtml" bson:"bodyHtml"`
+	Author      string             `json:"author" bson:"author"`
+	Votes       int                `json:"votes" bson:"votes"`
+	Controversy float64            `json:"controversy" bson:"controversy"`
+	Hot         float64            `json:"hot" bson:"hot"`
+	Rising      float64            `json:"rising" bson:"rising"`
+	Comments    int                `json:"comments" bson:"comments"`
+	Board       string             `json:"board" bson:"board"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+type PopularUser struct {
+	Name  string `json:"name" bson:"name"`
+	Votes int    `json:"votes" bson:"votes"`
+}
+
+type Session struct {
+	ID             primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	User           primitive.ObjectID `json:"user" bson:"user"`
+	RefreshHash    string             `json:"-" bson:"refreshHash"`
+	RefreshExpires time.Time          `json:"refreshExpires" bson:"refreshExpires"`
 }
 
 type Credentials struct {
@@ -115,23 +229,13 @@ type Credentials struct {
 	authorized bool
 }
 
-func (c *Credentials) Authorize() error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
-
-	if Collections.Users == nil {
-		return errors.New("missing users collection in Collections struct; types package")
-	}
-
-	var usr User
-	err := Collections.Users.FindOne(ctx, bson.M{"name": c.Name}).Decode(&usr)
-	if err != nil {
-		return err
+// Authorize checks the password against the stored user with the same name.
+func (c *Credentials) Authorize(usr User) error {
+	if usr.Name != c.Name {
+		return msgs.ErrNotAuthorized
 	}
 
-	log.Debug("password", "usr", usr.Password)
-	log.Debug("password", "  c", c.Password)
-	err = bcrypt.CompareHashAndPassword([]byte(usr.Password), []byte(c.Password))
+	err := bcrypt.CompareHashAndPassword([]byte(usr.Password), []byte(c.Password))
 	if err != nil {
 		return err
 	}
@@ -141,55 +245,20 @@ func (c *Credentials) Authorize() error {
 	return nil
 }
 
-func (c Credentials) ToUser() (User, error) {
-	if !c.authorized {
-		return User{}, msgs.ErrNotAuthorized
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
-	defer cancel()
-
-	var usr User
-	err := Collections.Users.FindOne(ctx, bson.M{"name": c.Name}).Decode(&usr)
-	if err != nil {
-		return User{}, err
-	}
-
-	return usr, nil
-}
-
-func AddAdministrators(newAdmins ...User) {
-	Administrators = append(Administrators, newAdmins...)
-}
-
-func RemoveAdministrators(remove ...User) {
-	for _, v := range remove {
-		indexToRemove := -1
-		for i, admin := range Administrators {
-			if admin.Equal(v) {
-				indexToRemove = i
-				break
-			}
-		}
-		if indexToRemove != -1 {
-			Administrators = append(Administrators[:indexToRemove], Administrators[indexToRemove+1:]...)
-		}
-	}
-}
-
 func IdToStruct(id *primitive.ObjectID, c *mongo.Collection) *mongo.SingleResult {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	ctx, cancel := context.WithTimeout(context.Background(), QueryTimeout)
 	defer cancel()
 
 	return c.FindOne(ctx, bson.M{"_id": id})
 }
 
 func IsAdmin(u User) bool {
-	return slices.Contains(Administrators, u)
+	return HasRole(u, RoleAdmin)
 }
 
+// IsModerator covers both the moderators of the board and global moderators.
 func IsModerator(b Board, u User) bool {
-	return slices.Contains(b.Moderators, u.ID)
+	return slices.Contains(b.Moderators, u.ID) || HasRole(u, RoleModerator)
 }
 
 func (p Post) CanEditPost(b Board, u User) bool {
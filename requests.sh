@@ -2,6 +2,7 @@
 # commands
 docker compose up -d
 make server
+make seed
 ./bin/server &
 jobs
 kill $(jobs -P)